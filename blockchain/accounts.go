@@ -0,0 +1,142 @@
+package blockchain
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/rpc"
+)
+
+// MaxAccountsPerRequest bounds the number of addresses one GetAccounts call may query.
+const MaxAccountsPerRequest = 100
+
+var ErrTooManyAccounts = errors.New("too many accounts")
+
+/**
+  *  AccountState is the state of one account at a block. BalanceWei is exact;
+  *  BalanceEther is the same amount as a decimal ether string. Code is only
+  *  filled in when it was requested.
+  */
+type AccountState struct {
+    Address      string `json:"address"`
+    BalanceWei   string `json:"balanceWei"`
+    BalanceEther string `json:"balanceEther"`
+    Nonce        uint64 `json:"nonce"`
+    Code         string `json:"code,omitempty"`
+}
+
+// GetBalance returns the balance of address in wei at block.
+func (bf *BlockFetcher) GetBalance(ctx context.Context, address common.Address, block BlockNumberArg) (*big.Int, error) {
+    var balance *big.Int
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        balance, err = n.Client.BalanceAt(ctx, address, block.BigInt())
+        return err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch balance of %s at %s: %v", address.Hex(), block, err)
+    }
+    return balance, nil
+}
+
+// GetTransactionCount returns the nonce of address at block.
+func (bf *BlockFetcher) GetTransactionCount(ctx context.Context, address common.Address, block BlockNumberArg) (uint64, error) {
+    var nonce uint64
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        nonce, err = n.Client.NonceAt(ctx, address, block.BigInt())
+        return err
+    })
+    if err != nil {
+        return 0, fmt.Errorf("failed to fetch nonce of %s at %s: %v", address.Hex(), block, err)
+    }
+    return nonce, nil
+}
+
+// GetCode returns the contract code at address at block; it is empty for externally owned accounts.
+func (bf *BlockFetcher) GetCode(ctx context.Context, address common.Address, block BlockNumberArg) ([]byte, error) {
+    var code []byte
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        code, err = n.Client.CodeAt(ctx, address, block.BigInt())
+        return err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch code of %s at %s: %v", address.Hex(), block, err)
+    }
+    return code, nil
+}
+
+// GetStorageAt returns the value of one storage slot of address at block.
+func (bf *BlockFetcher) GetStorageAt(ctx context.Context, address common.Address, slot common.Hash, block BlockNumberArg) (common.Hash, error) {
+    var value []byte
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        value, err = n.Client.StorageAt(ctx, address, slot, block.BigInt())
+        return err
+    })
+    if err != nil {
+        return common.Hash{}, fmt.Errorf("failed to fetch storage slot %s of %s at %s: %v", slot.Hex(), address.Hex(), block, err)
+    }
+    return common.BytesToHash(value), nil
+}
+
+/**
+  *  GetAccounts returns the balance and nonce of every address at block, and
+  *  their code when withCode is set. All lookups go to one node in a single
+  *  JSON-RPC batch. At most MaxAccountsPerRequest addresses are accepted.
+  */
+func (bf *BlockFetcher) GetAccounts(ctx context.Context, addresses []common.Address, block BlockNumberArg, withCode bool) ([]AccountState, error) {
+    if len(addresses) > MaxAccountsPerRequest {
+        return nil, fmt.Errorf("%w: at most %d addresses per request, got %d", ErrTooManyAccounts, MaxAccountsPerRequest, len(addresses))
+    }
+
+    balances := make([]hexutil.Big, len(addresses))
+    nonces := make([]hexutil.Uint64, len(addresses))
+    codes := make([]hexutil.Bytes, len(addresses))
+    batch := make([]rpc.BatchElem, 0, 3*len(addresses))
+    for i, address := range addresses {
+        batch = append(batch,
+            rpc.BatchElem{Method: "eth_getBalance", Args: []interface{}{address, block.String()}, Result: &balances[i]},
+            rpc.BatchElem{Method: "eth_getTransactionCount", Args: []interface{}{address, block.String()}, Result: &nonces[i]},
+        )
+        if withCode {
+            batch = append(batch, rpc.BatchElem{Method: "eth_getCode", Args: []interface{}{address, block.String()}, Result: &codes[i]})
+        }
+    }
+    if len(batch) > 0 {
+        err := bf.pool.Do(ctx, func(n *Node) error {
+            if err := n.RPCClient.BatchCallContext(ctx, batch); err != nil {
+                return err
+            }
+            for _, elem := range batch {
+                if elem.Error != nil {
+                    return elem.Error
+                }
+            }
+            return nil
+        })
+        if err != nil {
+            return nil, fmt.Errorf("failed to fetch accounts at %s: %v", block, err)
+        }
+    }
+
+    accounts := make([]AccountState, len(addresses))
+    for i, address := range addresses {
+        balance := balances[i].ToInt()
+        accounts[i] = AccountState{
+            Address:      address.Hex(),
+            BalanceWei:   balance.String(),
+            BalanceEther: weiToEther(balance),
+            Nonce:        uint64(nonces[i]),
+        }
+        if withCode {
+            accounts[i].Code = hexutil.Encode(codes[i])
+        }
+    }
+    return accounts, nil
+}
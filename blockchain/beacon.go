@@ -0,0 +1,183 @@
+package blockchain
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "math/big"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+)
+
+const beaconRequestTimeout = 5 * time.Second
+
+// BeaconProposer identifies the consensus-layer validator that proposed a post-merge block.
+type BeaconProposer struct {
+    Slot   uint64 `json:"slot"`
+    Index  uint64 `json:"index"`
+    Pubkey string `json:"pubkey"`
+}
+
+/**
+  *  posAdapter credits post-merge blocks to their fee recipient. With a beacon
+  *  node configured it also resolves the proposer of the slot the block was
+  *  built in through the standard beacon REST API. Beacon failures only drop
+  *  the proposer; the fee recipient is always reported.
+  */
+type posAdapter struct {
+    beacon *beaconClient
+}
+
+func newPoSAdapter(beaconURL string) *posAdapter {
+    a := &posAdapter{}
+    if beaconURL != "" {
+        a.beacon = newBeaconClient(beaconURL)
+    }
+    return a
+}
+
+func (a *posAdapter) Name() string {
+    return ConsensusPoS
+}
+
+func (a *posAdapter) Producer(ctx context.Context, header *types.Header) (*BlockProducer, error) {
+    producer := &BlockProducer{Address: header.Coinbase}
+    if a.beacon != nil {
+        proposer, err := a.beacon.proposer(ctx, header.Time)
+        if err != nil {
+            log.Printf("Failed to resolve beacon proposer of block %d: %v", header.Number, err)
+        } else {
+            producer.Proposer = proposer
+        }
+    }
+    return producer, nil
+}
+
+func (a *posAdapter) Validators(ctx context.Context, number *big.Int) ([]common.Address, error) {
+    return nil, ErrNoValidatorSet
+}
+
+/****************************************** beaconClient ******************************************/
+/**************************************************************************************************/
+
+type beaconClient struct {
+    url    string
+    client *http.Client
+
+    mu             sync.Mutex
+    genesisTime    uint64
+    secondsPerSlot uint64
+}
+
+func newBeaconClient(url string) *beaconClient {
+    return &beaconClient{
+        url:    strings.TrimRight(url, "/"),
+        client: &http.Client{Timeout: beaconRequestTimeout},
+    }
+}
+
+// proposer maps an execution block timestamp to its slot and looks up the slot proposer.
+func (c *beaconClient) proposer(ctx context.Context, timestamp uint64) (*BeaconProposer, error) {
+    genesisTime, secondsPerSlot, err := c.chainTiming(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if timestamp < genesisTime {
+        return nil, fmt.Errorf("block time %d precedes beacon genesis %d", timestamp, genesisTime)
+    }
+    slot := (timestamp - genesisTime) / secondsPerSlot
+
+    var header struct {
+        Data struct {
+            Header struct {
+                Message struct {
+                    ProposerIndex string `json:"proposer_index"`
+                } `json:"message"`
+            } `json:"header"`
+        } `json:"data"`
+    }
+    if err := c.get(ctx, fmt.Sprintf("/eth/v1/beacon/headers/%d", slot), &header); err != nil {
+        return nil, err
+    }
+    index, err := strconv.ParseUint(header.Data.Header.Message.ProposerIndex, 10, 64)
+    if err != nil {
+        return nil, fmt.Errorf("invalid proposer index %q", header.Data.Header.Message.ProposerIndex)
+    }
+
+    // Validator keys never change, so the head state answers for any slot.
+    var validator struct {
+        Data struct {
+            Validator struct {
+                Pubkey string `json:"pubkey"`
+            } `json:"validator"`
+        } `json:"data"`
+    }
+    if err := c.get(ctx, fmt.Sprintf("/eth/v1/beacon/states/head/validators/%d", index), &validator); err != nil {
+        return nil, err
+    }
+    return &BeaconProposer{
+        Slot:   slot,
+        Index:  index,
+        Pubkey: validator.Data.Validator.Pubkey,
+    }, nil
+}
+
+// chainTiming loads the genesis time and slot duration once.
+func (c *beaconClient) chainTiming(ctx context.Context) (uint64, uint64, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if c.secondsPerSlot != 0 {
+        return c.genesisTime, c.secondsPerSlot, nil
+    }
+    var genesis struct {
+        Data struct {
+            GenesisTime string `json:"genesis_time"`
+        } `json:"data"`
+    }
+    if err := c.get(ctx, "/eth/v1/beacon/genesis", &genesis); err != nil {
+        return 0, 0, err
+    }
+    var spec struct {
+        Data struct {
+            SecondsPerSlot string `json:"SECONDS_PER_SLOT"`
+        } `json:"data"`
+    }
+    if err := c.get(ctx, "/eth/v1/config/spec", &spec); err != nil {
+        return 0, 0, err
+    }
+    genesisTime, err := strconv.ParseUint(genesis.Data.GenesisTime, 10, 64)
+    if err != nil {
+        return 0, 0, fmt.Errorf("invalid genesis time %q", genesis.Data.GenesisTime)
+    }
+    secondsPerSlot, err := strconv.ParseUint(spec.Data.SecondsPerSlot, 10, 64)
+    if err != nil || secondsPerSlot == 0 {
+        return 0, 0, fmt.Errorf("invalid seconds per slot %q", spec.Data.SecondsPerSlot)
+    }
+    c.genesisTime, c.secondsPerSlot = genesisTime, secondsPerSlot
+    return genesisTime, secondsPerSlot, nil
+}
+
+func (c *beaconClient) get(ctx context.Context, path string, result interface{}) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+path, nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Accept", "application/json")
+    resp, err := c.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("beacon node returned %s for %s", resp.Status, path)
+    }
+    return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,205 @@
+package blockchain
+
+import (
+    "context"
+    "fmt"
+    "math/big"
+    "sync"
+    "sync/atomic"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/lru"
+    "github.com/ethereum/go-ethereum/core/types"
+    "golang.org/x/sync/singleflight"
+)
+
+const defaultBlockCacheSize = 256
+
+// BlockCacheStats reports the block cache occupancy and hit ratio since startup.
+type BlockCacheStats struct {
+    Size     int    `json:"size"`
+    Capacity int    `json:"capacity"`
+    Hits     uint64 `json:"hits"`
+    Misses   uint64 `json:"misses"`
+}
+
+/**
+  *  blockCache holds converted blocks by hash, plus an index from canonical
+  *  block number to hash. Blocks are immutable once mined, so only the number
+  *  index needs invalidating: a new head or a reorg drops every number at or
+  *  above the first replaced block. Concurrent misses for the same block share
+  *  a single fetch.
+  */
+type blockCache struct {
+    mu       sync.Mutex
+    capacity int
+    byHash   lru.BasicLRU[common.Hash, *Block]
+    byNumber lru.BasicLRU[uint64, common.Hash]
+    gen      uint64 // bumped by every invalidation of the number index
+
+    inFlight singleflight.Group
+    hits     atomic.Uint64
+    misses   atomic.Uint64
+}
+
+func newBlockCache(size int) *blockCache {
+    return &blockCache{
+        capacity: size,
+        byHash:   lru.NewBasicLRU[common.Hash, *Block](size),
+        byNumber: lru.NewBasicLRU[uint64, common.Hash](size),
+    }
+}
+
+func (c *blockCache) getByNumber(number uint64) (*Block, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    hash, ok := c.byNumber.Get(number)
+    if !ok {
+        return nil, false
+    }
+    return c.byHash.Get(hash)
+}
+
+func (c *blockCache) getByHash(hash common.Hash) (*Block, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.byHash.Get(hash)
+}
+
+func (c *blockCache) generation() uint64 {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.gen
+}
+
+/**
+  *  add caches block. It is indexed by number only when canonical is set and
+  *  the number index was not invalidated since gen.
+  */
+func (c *blockCache) add(block *Block, canonical bool, gen uint64) {
+    hash := common.HexToHash(block.Hash)
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.byHash.Add(hash, block)
+    if canonical && c.gen == gen {
+        c.byNumber.Add(block.Number, hash)
+    }
+}
+
+// invalidateFrom drops the number index at and above number, keeping keep if it is the block at number.
+func (c *blockCache) invalidateFrom(number uint64, keep common.Hash) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.gen++
+    for _, n := range c.byNumber.Keys() {
+        if n < number {
+            continue
+        }
+        if hash, _ := c.byNumber.Peek(n); n == number && hash == keep {
+            continue
+        }
+        c.byNumber.Remove(n)
+    }
+}
+
+func (c *blockCache) stats() BlockCacheStats {
+    c.mu.Lock()
+    size := c.byHash.Len()
+    c.mu.Unlock()
+    return BlockCacheStats{
+        Size:     size,
+        Capacity: c.capacity,
+        Hits:     c.hits.Load(),
+        Misses:   c.misses.Load(),
+    }
+}
+
+/**
+  *  load returns the cached block, or runs fetch once for all concurrent
+  *  callers with the same key. The fetch is detached from the caller that
+  *  started it, so one client going away does not fail the others waiting on
+  *  the same block. Blocks fetched by number are canonical and are also added
+  *  to the number index.
+  */
+func (c *blockCache) load(ctx context.Context, key string, canonical bool, cached func() (*Block, bool), fetch func(ctx context.Context) (*Block, bool, error)) (*Block, error) {
+    if block, ok := cached(); ok {
+        c.hits.Add(1)
+        return block, nil
+    }
+    c.misses.Add(1)
+
+    ch := c.inFlight.DoChan(key, func() (interface{}, error) {
+        gen := c.generation()
+        block, complete, err := fetch(context.WithoutCancel(ctx))
+        if err != nil {
+            return nil, err
+        }
+        if complete {
+            c.add(block, canonical, gen)
+        }
+        return block, nil
+    })
+    select {
+    case res := <-ch:
+        if res.Err != nil {
+            return nil, res.Err
+        }
+        return res.Val.(*Block), nil
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+}
+
+/**
+  *  GetBlockByNumber returns the converted block at number, or the latest block
+  *  when number is nil. Negative numbers select the tags of rpc.BlockNumber.
+  *  Blocks at an explicit number are served from the block cache; the returned
+  *  Block is shared and must not be modified.
+  */
+func (bf *BlockFetcher) GetBlockByNumber(ctx context.Context, number *big.Int) (*Block, error) {
+    if number == nil || number.Sign() < 0 {
+        block, _, err := bf.fetchBlockByNumber(ctx, number)
+        return block, err
+    }
+    n := number.Uint64()
+    return bf.blocks.load(ctx, fmt.Sprintf("number:%d", n), true,
+        func() (*Block, bool) { return bf.blocks.getByNumber(n) },
+        func(ctx context.Context) (*Block, bool, error) { return bf.fetchBlockByNumber(ctx, new(big.Int).SetUint64(n)) },
+    )
+}
+
+// GetBlockByHash returns the converted block with the given hash, canonical or not, through the block cache.
+func (bf *BlockFetcher) GetBlockByHash(ctx context.Context, hash common.Hash) (*Block, error) {
+    return bf.blocks.load(ctx, "hash:"+hash.Hex(), false,
+        func() (*Block, bool) { return bf.blocks.getByHash(hash) },
+        func(ctx context.Context) (*Block, bool, error) { return bf.fetchBlockByHash(ctx, hash) },
+    )
+}
+
+// GetBlock returns the block selected by a number, tag or hash.
+func (bf *BlockFetcher) GetBlock(ctx context.Context, ref BlockNumberOrHash) (*Block, error) {
+    if ref.Hash != nil {
+        return bf.GetBlockByHash(ctx, *ref.Hash)
+    }
+    if *ref.Number == EarliestBlock {
+        return bf.GetBlockByNumber(ctx, big.NewInt(0))
+    }
+    return bf.GetBlockByNumber(ctx, ref.Number.BigInt())
+}
+
+// BlockCacheStats returns the block cache counters.
+func (bf *BlockFetcher) BlockCacheStats() BlockCacheStats {
+    return bf.blocks.stats()
+}
+
+// ObserveReorg drops the cached number index from the first block the reorg replaced.
+func (bf *BlockFetcher) ObserveReorg(reorg *Reorg) {
+    if len(reorg.Removed) == 0 {
+        return
+    }
+    bf.blocks.invalidateFrom(reorg.Removed[0].Number, common.Hash{})
+}
+
+func (bf *BlockFetcher) invalidateBlocks(header *types.Header) {
+    bf.blocks.invalidateFrom(header.Number.Uint64(), header.Hash())
+}
@@ -0,0 +1,149 @@
+package blockchain
+
+import (
+    "context"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/ethereum/go-ethereum/common"
+)
+
+func testBlock(number uint64, fork byte) *Block {
+    return &Block{Number: number, Hash: common.BytesToHash([]byte{fork, byte(number)}).Hex()}
+}
+
+// addCanonical caches blocks from to to of fork as the canonical chain.
+func addCanonical(c *blockCache, from, to uint64, fork byte) {
+    for n := from; n <= to; n++ {
+        c.add(testBlock(n, fork), true, c.generation())
+    }
+}
+
+func TestBlockCacheEvictsLeastRecentlyUsed(t *testing.T) {
+    c := newBlockCache(2)
+    addCanonical(c, 1, 2, 0)
+    // Reading block 1 makes block 2 the least recently used one.
+    if _, ok := c.getByNumber(1); !ok {
+        t.Fatal("block 1 is not cached")
+    }
+    addCanonical(c, 3, 3, 0)
+
+    for number, want := range map[uint64]bool{1: true, 2: false, 3: true} {
+        if _, ok := c.getByHash(common.HexToHash(testBlock(number, 0).Hash)); ok != want {
+            t.Fatalf("block %d cached by hash: %v, want %v", number, ok, want)
+        }
+    }
+    if got := c.stats(); got.Size != 2 || got.Capacity != 2 {
+        t.Fatalf("stats %+v, want 2 of 2 blocks", got)
+    }
+}
+
+func TestBlockCacheReorgInvalidatesNumbers(t *testing.T) {
+    c := newBlockCache(16)
+    addCanonical(c, 1, 5, 0)
+
+    // A reorg replaces blocks 4 and 5: they are no longer served by number, but stay reachable by hash.
+    c.invalidateFrom(4, common.Hash{})
+    for number := uint64(1); number <= 5; number++ {
+        _, ok := c.getByNumber(number)
+        if want := number < 4; ok != want {
+            t.Fatalf("block %d cached by number: %v, want %v", number, ok, want)
+        }
+        if _, ok := c.getByHash(common.HexToHash(testBlock(number, 0).Hash)); !ok {
+            t.Fatalf("block %d is no longer cached by hash", number)
+        }
+    }
+
+    addCanonical(c, 4, 5, 1)
+    if block, ok := c.getByNumber(5); !ok || block.Hash != testBlock(5, 1).Hash {
+        t.Fatalf("block 5 by number is %v, want the replacement", block)
+    }
+}
+
+func TestBlockCacheNewHeadKeepsItsBlock(t *testing.T) {
+    c := newBlockCache(16)
+    addCanonical(c, 1, 3, 0)
+
+    // A head at height 3 that matches the cached block only drops the heights above it.
+    addCanonical(c, 4, 4, 0)
+    c.invalidateFrom(3, common.HexToHash(testBlock(3, 0).Hash))
+    if _, ok := c.getByNumber(3); !ok {
+        t.Fatal("block 3 was dropped although the head confirmed it")
+    }
+    if _, ok := c.getByNumber(4); ok {
+        t.Fatal("block 4 is still served above the new head")
+    }
+
+    // A different head at height 3 drops it as well.
+    c.invalidateFrom(3, common.HexToHash(testBlock(3, 1).Hash))
+    if _, ok := c.getByNumber(3); ok {
+        t.Fatal("block 3 is still served after being replaced")
+    }
+}
+
+func TestBlockCacheStaleFetchIsNotIndexed(t *testing.T) {
+    c := newBlockCache(16)
+    gen := c.generation()
+    // A reorg lands while block 7 is being fetched.
+    c.invalidateFrom(7, common.Hash{})
+    c.add(testBlock(7, 0), true, gen)
+
+    if _, ok := c.getByNumber(7); ok {
+        t.Fatal("a block fetched before the reorg was indexed by number")
+    }
+    if _, ok := c.getByHash(common.HexToHash(testBlock(7, 0).Hash)); !ok {
+        t.Fatal("a block fetched before the reorg was not cached by hash")
+    }
+}
+
+func TestBlockCacheLoad(t *testing.T) {
+    c := newBlockCache(16)
+    release := make(chan struct{})
+    var fetches atomic.Int32
+    fetch := func(ctx context.Context) (*Block, bool, error) {
+        fetches.Add(1)
+        <-release
+        return testBlock(9, 0), true, nil
+    }
+    cached := func() (*Block, bool) { return c.getByNumber(9) }
+
+    // Concurrent misses share one fetch.
+    var wg sync.WaitGroup
+    for i := 0; i < 4; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if _, err := c.load(context.Background(), "number:9", true, cached, fetch); err != nil {
+                t.Errorf("load: %v", err)
+            }
+        }()
+    }
+    // Let every caller miss and join the fetch before it completes.
+    for c.misses.Load() < 4 {
+        time.Sleep(time.Millisecond)
+    }
+    time.Sleep(20 * time.Millisecond)
+    close(release)
+    wg.Wait()
+    if got := fetches.Load(); got != 1 {
+        t.Fatalf("%d fetches for concurrent misses, want 1", got)
+    }
+
+    if _, err := c.load(context.Background(), "number:9", true, cached, fetch); err != nil {
+        t.Fatalf("load: %v", err)
+    }
+    if got := c.stats(); got.Hits != 1 || got.Misses != 4 || fetches.Load() != 1 {
+        t.Fatalf("stats %+v after %d fetches, want 1 hit, 4 misses and 1 fetch", got, fetches.Load())
+    }
+
+    // Incomplete blocks are returned but not cached.
+    incomplete := func(ctx context.Context) (*Block, bool, error) { return testBlock(10, 0), false, nil }
+    if _, err := c.load(context.Background(), "number:10", true, func() (*Block, bool) { return c.getByNumber(10) }, incomplete); err != nil {
+        t.Fatalf("load: %v", err)
+    }
+    if _, ok := c.getByNumber(10); ok {
+        t.Fatal("an incomplete block was cached")
+    }
+}
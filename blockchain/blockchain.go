@@ -2,24 +2,37 @@ package blockchain
 
 import (
     "context"
+    "errors"
     "fmt"
     "math/big"
     "time"
     "sync"
     "log"
-    "runtime"
 
+    "github.com/ethereum/go-ethereum"
     "github.com/ethereum/go-ethereum/common"
     "github.com/ethereum/go-ethereum/core/types"
-    "github.com/ethereum/go-ethereum/ethclient"
     "github.com/ethereum/go-ethereum/rpc"
+    "golang.org/x/sync/errgroup"
 )
 
+/**
+  *  Amounts are exact decimal strings: ValueWei in wei, ValueEther in ether.
+  *  The float64 Value is kept for existing clients only.
+  */
 type BlockTransaction struct {
-    Hash  string  `json:"hash"`
-    From  string  `json:"from"`
-    To    string  `json:"to"`
-    Value float64 `json:"value"`
+    Hash       string  `json:"hash"`
+    From       string  `json:"from"`
+    To         string  `json:"to"`
+    ValueWei   string  `json:"valueWei"`
+    ValueEther string  `json:"valueEther"`
+    // Deprecated: Value is rounded to float64 ether; use ValueWei.
+    Value      float64 `json:"value"`
+
+    // Receipt fields, set on mined transactions only. Status is 1 on success and 0 on failure.
+    GasUsed           uint64  `json:"gasUsed,omitempty"`
+    EffectiveGasPrice string  `json:"effectiveGasPrice,omitempty"`
+    Status            *uint64 `json:"status,omitempty"`
 }
 
 type Block struct {
@@ -30,83 +43,297 @@ type Block struct {
     TransactionRoot  string             `json:"transactionRoot"`
     Timestamp        time.Time          `json:"timestamp"`
     Validator        string             `json:"validator"`
+    Proposer         *BeaconProposer    `json:"proposer,omitempty"`
     Size             uint64             `json:"size"`
     GasUsed          uint64             `json:"gasUsed"`
     GasLimit         uint64             `json:"gasLimit"`
     Transactions     []BlockTransaction `json:"transactions"`
     TransactionCount int                `json:"transactionCount"`
+    TotalFeesWei     string             `json:"totalFeesWei"`
+    TotalFeesGwei    string             `json:"totalFeesGwei"`
+    TotalFeesEther   string             `json:"totalFeesEther"`
+    // BaseFeePerGas is empty before London. BurntFeesWei plus PriorityFeesWei equals TotalFeesWei.
+    BaseFeePerGas    string             `json:"baseFeePerGas,omitempty"`
+    BurntFeesWei     string             `json:"burntFeesWei"`
+    PriorityFeesWei  string             `json:"priorityFeesWei"`
+    // Deprecated: TotalFees is rounded to float64 ether; use TotalFeesWei.
     TotalFees        float64            `json:"totalFees"`
+    // ReceiptsMissing is set when the node could not return the receipts; the
+    // fee fields are then empty and transactions carry no receipt fields.
+    ReceiptsMissing  bool               `json:"receiptsMissing,omitempty"`
 }
 
+// blockFetchWorkers bounds the concurrent block fetches of one multi-block request.
+const blockFetchWorkers = 8
+
 type BlockFetcher struct {
-    Client     *ethclient.Client
-    RPCClient  *rpc.Client
+    pool   *NodePool
+    config FetcherConfig
+
+    consensusMu      sync.Mutex
+    consensusAdapter ConsensusAdapter
+    blockTimes       *BlockTimeStats
+    blocks           *blockCache
+    transfers        *transferCache
+
+    chainIDMu        sync.Mutex
+    chainID          *big.Int
+
+    producerWarning  sync.Once
+}
+
+// FetcherConfig tunes the BlockFetcher. Zero values select the defaults.
+type FetcherConfig struct {
+    // MaxLogBlockRange caps the number of blocks a single GetLogs call may span.
+    MaxLogBlockRange  uint64
+    // Consensus selects the validator lookup (ConsensusClique, ConsensusIBFT,
+    // ConsensusQBFT, ConsensusEthash or ConsensusPoS). Empty detects it from the node.
+    Consensus         string
+    // BeaconURL is an optional beacon node REST endpoint used to resolve post-merge proposers.
+    BeaconURL         string
+    // CliqueRPCFallback resolves Clique signers with clique_getSigner when local recovery fails.
+    CliqueRPCFallback bool
+    // CliqueEpoch is the Clique checkpoint interval, 30000 blocks by default.
+    CliqueEpoch       uint64
+    // BlockTimeWindow is the number of recent blocks block time statistics cover, 64 by default.
+    BlockTimeWindow   int
+    // MetricsInterval is how often the MetricsCollector refreshes its snapshot, 15s by default.
+    MetricsInterval   time.Duration
+    // BlockCacheSize is the number of converted blocks kept in memory, 256 by default.
+    BlockCacheSize    int
 }
 
 type MiningController struct {
     clients []*rpc.Client
+    urls    []string
     mu      sync.Mutex
 }
 
-func NewBlockFetcher(nodeURL string) (*BlockFetcher, error) {
-    client, err := ethclient.Dial(nodeURL)
-    if err != nil {
-        return nil, fmt.Errorf("failed to connect to Ethereum node: %v", err)
+func NewBlockFetcher(pool *NodePool, config FetcherConfig) *BlockFetcher {
+    if config.MaxLogBlockRange == 0 {
+        config.MaxLogBlockRange = defaultMaxLogBlockRange
     }
-
-    rpcClient, err := rpc.Dial(nodeURL)
-    if err != nil {
-        return nil, fmt.Errorf("failed to connect to Ethereum RPC: %v", err)
+    if config.CliqueEpoch == 0 {
+        config.CliqueEpoch = defaultCliqueEpoch
+    }
+    if config.BlockTimeWindow < 2 {
+        config.BlockTimeWindow = defaultBlockTimeWindow
+    }
+    if config.MetricsInterval <= 0 {
+        config.MetricsInterval = defaultMetricsInterval
+    }
+    if config.BlockCacheSize <= 0 {
+        config.BlockCacheSize = defaultBlockCacheSize
     }
-
     return &BlockFetcher{
-        Client:    client,
-        RPCClient: rpcClient,
-    }, nil
+        pool:       pool,
+        config:     config,
+        blockTimes: NewBlockTimeStats(config.BlockTimeWindow),
+        blocks:     newBlockCache(config.BlockCacheSize),
+        transfers:  newTransferCache(),
+    }
 }
 
-func (bf *BlockFetcher) GetLatestBlocks(ctx context.Context, count int) ([]Block, error) {
-    var blocks []Block
+func (bf *BlockFetcher) Pool() *NodePool {
+    return bf.pool
+}
+
+// Close releases every node connection held by the fetcher.
+func (bf *BlockFetcher) Close() {
+    bf.pool.Close()
+}
+
+// SubscribeNewHead subscribes to new heads on a healthy node of the pool.
+func (bf *BlockFetcher) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+    return bf.pool.Subscribe(ctx, func(n *Node) (ethereum.Subscription, error) {
+        return n.Client.SubscribeNewHead(ctx, ch)
+    })
+}
+
+// SubscribeFilterLogs subscribes to logs matching q on a healthy node of the pool.
+func (bf *BlockFetcher) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+    return bf.pool.Subscribe(ctx, func(n *Node) (ethereum.Subscription, error) {
+        return n.Client.SubscribeFilterLogs(ctx, q, ch)
+    })
+}
 
-    header, err := bf.Client.HeaderByNumber(ctx, nil)
+// SubscribePendingTransactions subscribes to the hashes of transactions entering the mempool.
+func (bf *BlockFetcher) SubscribePendingTransactions(ctx context.Context, ch chan<- common.Hash) (ethereum.Subscription, error) {
+    return bf.pool.Subscribe(ctx, func(n *Node) (ethereum.Subscription, error) {
+        return n.RPCClient.EthSubscribe(ctx, ch, "newPendingTransactions")
+    })
+}
+
+func (bf *BlockFetcher) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+    var header *types.Header
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        header, err = n.Client.HeaderByNumber(ctx, number)
+        return err
+    })
     if err != nil {
-        return nil, fmt.Errorf("failed to get latest block header: %v", err)
+        return nil, fmt.Errorf("failed to get header %v: %v", number, err)
     }
+    return header, nil
+}
 
-    currentBlockNum := header.Number.Uint64()
+func (bf *BlockFetcher) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+    var header *types.Header
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        header, err = n.Client.HeaderByHash(ctx, hash)
+        return err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to get header %s: %v", hash.Hex(), err)
+    }
+    return header, nil
+}
 
-    for i := 0; i < count; i++ {
-        blockNum := big.NewInt(0).SetUint64(currentBlockNum - uint64(i))
-        block, err := bf.GetBlockByNumber(ctx, blockNum)
+/**
+  *  GetLatestBlocks returns up to count blocks in descending order, starting at
+  *  from (a number or tag) and stopping at genesis. A from above the current
+  *  head, or the pending tag, is rejected with ErrInvalidBlockRange.
+  */
+func (bf *BlockFetcher) GetLatestBlocks(ctx context.Context, count int, from BlockNumberArg) ([]Block, error) {
+    if from == PendingBlock {
+        return nil, fmt.Errorf("%w: fromBlock cannot be pending", ErrInvalidBlockRange)
+    }
+    start, err := bf.resolveBlockNumber(ctx, from)
+    if err != nil {
+        return nil, err
+    }
+    if !from.IsTag() {
+        head, err := bf.resolveBlockNumber(ctx, LatestBlock)
         if err != nil {
-            return nil, fmt.Errorf("failed to get block %d: %v", blockNum, err)
+            return nil, err
         }
-        blocks = append(blocks, *block)
+        if start > head {
+            return nil, fmt.Errorf("%w: fromBlock %d is after the latest block %d", ErrInvalidBlockRange, start, head)
+        }
+    }
+    if count <= 0 {
+        return []Block{}, nil
+    }
+    if uint64(count) > start+1 {
+        count = int(start + 1)
     }
 
+    numbers := make([]uint64, count)
+    for i := range numbers {
+        numbers[i] = start - uint64(i)
+    }
+    return bf.getBlocks(ctx, numbers)
+}
+
+// getBlocks fetches the given blocks concurrently, at most blockFetchWorkers at a time, and returns them in the same order.
+func (bf *BlockFetcher) getBlocks(ctx context.Context, numbers []uint64) ([]Block, error) {
+    blocks := make([]Block, len(numbers))
+    g, gctx := errgroup.WithContext(ctx)
+    g.SetLimit(blockFetchWorkers)
+    for i, number := range numbers {
+        g.Go(func() error {
+            block, err := bf.GetBlockByNumber(gctx, new(big.Int).SetUint64(number))
+            if err != nil {
+                return fmt.Errorf("failed to get block %d: %v", number, err)
+            }
+            blocks[i] = *block
+            return nil
+        })
+    }
+    if err := g.Wait(); err != nil {
+        return nil, err
+    }
     return blocks, nil
 }
 
-func (bf *BlockFetcher) GetBlockByNumber(ctx context.Context, number *big.Int) (*Block, error) {
-    block, err := bf.Client.BlockByNumber(ctx, number)
+// fetchBlockByNumber converts a block from the node. complete is false when a later fetch may return more data.
+func (bf *BlockFetcher) fetchBlockByNumber(ctx context.Context, number *big.Int) (*Block, bool, error) {
+    var block *types.Block
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        block, err = n.Client.BlockByNumber(ctx, number)
+        return err
+    })
     if err != nil {
-        return nil, fmt.Errorf("failed to get block %d: %v", number, err)
+        return nil, false, fmt.Errorf("failed to get block %d: %w", number, err)
     }
+    pending := number != nil && number.Cmp(PendingBlock.BigInt()) == 0
+    return bf.convertBlock(ctx, block, pending)
+}
 
-    totalFees := calculateTotalFees(block)
+func (bf *BlockFetcher) fetchBlockByHash(ctx context.Context, hash common.Hash) (*Block, bool, error) {
+    var block *types.Block
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        block, err = n.Client.BlockByHash(ctx, hash)
+        return err
+    })
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to get block %s: %w", hash.Hex(), err)
+    }
+    return bf.convertBlock(ctx, block, false)
+}
+
+/**
+  *  convertBlock builds the Block of a node block. Pending blocks have no
+  *  receipts and no seal, so their fees are zero, their transactions carry no
+  *  receipt fields and their validator is left unresolved.
+  */
+func (bf *BlockFetcher) convertBlock(ctx context.Context, block *types.Block, pending bool) (*Block, bool, error) {
+    number := block.Number()
     txs := convertTransactions(block.Transactions())
+    fees := blockFees{Total: big.NewInt(0), Burnt: big.NewInt(0), Priority: big.NewInt(0)}
+    receiptsMissing := false
+    if !pending {
+        receipts, err := bf.fetchReceipts(ctx, block)
+        if err != nil && !errors.Is(err, errReceiptsMissing) {
+            return nil, false, err
+        }
+        if err != nil {
+            log.Printf("Fees of block %d unavailable: %v", number, err)
+            receiptsMissing = true
+        } else {
+            fees = calculateFees(block, receipts)
+            for i, tx := range block.Transactions() {
+                status := receipts[i].Status
+                txs[i].GasUsed = receipts[i].GasUsed
+                txs[i].EffectiveGasPrice = effectiveGasPrice(tx, receipts[i], block.BaseFee()).String()
+                txs[i].Status = &status
+            }
+        }
+    }
+
+    var baseFee string
+    if block.BaseFee() != nil {
+        baseFee = block.BaseFee().String()
+    }
 
     /**
-      *  Validator / Signer fetching using clique_getSigner
+      *  Validator attribution through the consensus adapter of the network
       */
-    var validator string
-    err = bf.RPCClient.CallContext(ctx, &validator, "clique_getSigner", block.Hash().Hex())
-    if err != nil {
-        log.Printf("Failed to fetch validator for block %d: %v", number, err)
-        validator = "0x0000000000000000000000000000000000000000"
+    validator := "0x0000000000000000000000000000000000000000"
+    var proposer *BeaconProposer
+    complete := false
+    if !pending {
+        producer, err := bf.blockProducer(ctx, block.Header())
+        switch {
+        case err == nil:
+            validator = producer.Address.Hex()
+            proposer = producer.Proposer
+            complete = !receiptsMissing
+        case errors.Is(err, errProducerUnavailable):
+            // Fetching the block again cannot resolve it, so it is as complete as this configuration allows.
+            bf.producerWarning.Do(func() {
+                log.Printf("Validators cannot be resolved, blocks are served and cached without them: %v", err)
+            })
+            complete = !receiptsMissing
+        default:
+            log.Printf("Failed to fetch validator for block %d: %v", number, err)
+        }
     }
 
-    return &Block{
+    result := &Block{
         Number:           block.NumberU64(),
         Hash:             block.Hash().Hex(),
         ParentHash:       block.ParentHash().Hex(),
@@ -114,80 +341,112 @@ func (bf *BlockFetcher) GetBlockByNumber(ctx context.Context, number *big.Int) (
         TransactionRoot:  block.TxHash().Hex(),
         Timestamp:        time.Unix(int64(block.Time()), 0),
         Validator:        validator,
+        Proposer:         proposer,
         Size:             block.Size(),
         GasUsed:          block.GasUsed(),
         GasLimit:         block.GasLimit(),
         Transactions:     txs,
         TransactionCount: len(block.Transactions()),
-        TotalFees:        totalFees,
-    }, nil
+        TotalFeesWei:     fees.Total.String(),
+        TotalFeesGwei:    weiToGwei(fees.Total),
+        TotalFeesEther:   weiToEther(fees.Total),
+        TotalFees:        weiToEtherFloat(fees.Total),
+        BaseFeePerGas:    baseFee,
+        BurntFeesWei:     fees.Burnt.String(),
+        PriorityFeesWei:  fees.Priority.String(),
+    }
+    if receiptsMissing {
+        result.TotalFeesWei, result.TotalFeesGwei, result.TotalFeesEther = "", "", ""
+        result.BurntFeesWei, result.PriorityFeesWei = "", ""
+        result.ReceiptsMissing = true
+    }
+    return result, complete, nil
 }
 
-func (bf *BlockFetcher) GetValidators(ctx context.Context) ([]string, error) {
-    var validators []string
-    err := bf.RPCClient.CallContext(ctx, &validators, "clique_getSigners")
+func (bf *BlockFetcher) blockProducer(ctx context.Context, header *types.Header) (*BlockProducer, error) {
+    adapter, err := bf.consensus(ctx)
     if err != nil {
-        return nil, fmt.Errorf("failed to fetch validators: %v", err)
+        return nil, err
     }
-    return validators, nil
+    return adapter.Producer(ctx, header)
 }
 
-func calculateTotalFees(block *types.Block) float64 {
-    total := big.NewInt(0)
-    for _, tx := range block.Transactions() {
-        fee := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
-        total.Add(total, fee)
+func (bf *BlockFetcher) GetValidators(ctx context.Context) ([]string, error) {
+    adapter, err := bf.consensus(ctx)
+    if err != nil {
+        return nil, err
+    }
+    validators, err := adapter.Validators(ctx, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch validators: %w", err)
+    }
+    result := make([]string, len(validators))
+    for i, validator := range validators {
+        result[i] = validator.Hex()
     }
-    weiPerEth := new(big.Float).SetInt(big.NewInt(1e18))
-    feeEth := new(big.Float).Quo(new(big.Float).SetInt(total), weiPerEth)
-    f, _ := feeEth.Float64()
-    return f
+    return result, nil
 }
 
 func convertTransactions(txs types.Transactions) []BlockTransaction {
     result := make([]BlockTransaction, len(txs))
     for i, tx := range txs {
-        from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
-        if err != nil {
-            from = common.HexToAddress("0x0000000000000000000000000000000000000000")
-        }
+        result[i] = convertTransaction(tx)
+    }
+    return result
+}
 
-        var to string
-        if tx.To() != nil {
-            to = tx.To().Hex()
-        } else {
-            to = "0x0000000000000000000000000000000000000000"
-        }
+func convertTransaction(tx *types.Transaction) BlockTransaction {
+    from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+    if err != nil {
+        from = common.HexToAddress("0x0000000000000000000000000000000000000000")
+    }
 
-        weiPerEth := new(big.Float).SetInt(big.NewInt(1e18))
-        valueEth := new(big.Float).Quo(new(big.Float).SetInt(tx.Value()), weiPerEth)
-        value, _ := valueEth.Float64()
+    var to string
+    if tx.To() != nil {
+        to = tx.To().Hex()
+    } else {
+        to = "0x0000000000000000000000000000000000000000"
+    }
 
-        result[i] = BlockTransaction{
-            Hash:  tx.Hash().Hex(),
-            From:  from.Hex(),
-            To:    to,
-            Value: value,
-        }
+    return BlockTransaction{
+        Hash:       tx.Hash().Hex(),
+        From:       from.Hex(),
+        To:         to,
+        ValueWei:   tx.Value().String(),
+        ValueEther: weiToEther(tx.Value()),
+        Value:      weiToEtherFloat(tx.Value()),
     }
-    return result
 }
 
 /****************************************** MiningController methods ******************************************/
 /**************************************************************************************************************/
 
-func NewMiningController(nodeURLs []string) (*MiningController, error) {
+func NewMiningController(ctx context.Context, nodeURLs []string) (*MiningController, error) {
     mc := &MiningController{}
     for _, url := range nodeURLs {
-        client, err := rpc.Dial(url)
+        client, err := rpc.DialContext(ctx, url)
         if err != nil {
+            mc.Close()
             return nil, err
         }
         mc.clients = append(mc.clients, client)
+        mc.urls = append(mc.urls, url)
     }
     return mc, nil
 }
 
+// Close closes the RPC client of every node.
+func (mc *MiningController) Close() {
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+
+    for _, client := range mc.clients {
+        client.Close()
+    }
+    mc.clients = nil
+    mc.urls = nil
+}
+
 func (mc *MiningController) ToggleMining(ctx context.Context, start bool) ([]bool, error) {
     mc.mu.Lock()
     defer mc.mu.Unlock()
@@ -224,63 +483,20 @@ func (mc *MiningController) GetMiningStatus(ctx context.Context) ([]bool, error)
     return statuses, nil
 }
 
-func (bf *BlockFetcher) GetNetworkMetrics(ctx context.Context) (map[string]interface{}, error) {
-    metrics := make(map[string]interface{})
-
-
-    blockTime, err := bf.calculateAverageBlockTime(ctx)
-    if err != nil {
-        return nil, fmt.Errorf("failed to calculate average block time: %v", err)
-    }
-    metrics["averageBlockTime"] = blockTime
-
-
-    latestBlock, err := bf.Client.BlockByNumber(ctx, nil)
-    if err != nil {
-        return nil, fmt.Errorf("failed to fetch latest block: %v", err)
-    }
-    metrics["difficulty"] = latestBlock.Difficulty().String()
-
-
-    var hashrate string
-    err = bf.RPCClient.CallContext(ctx, &hashrate, "eth_hashrate")
-    if err != nil {
-        return nil, fmt.Errorf("failed to fetch network hashrate: %v", err)
-    }
-    metrics["hashrate"] = hashrate
-
-
-    latency := bf.calculateNetworkLatency()
-    metrics["latency"] = latency
-
-
-    var memStats runtime.MemStats
-    runtime.ReadMemStats(&memStats)
-    metrics["memoryUsage"] = fmt.Sprintf("%.2f MB", float64(memStats.Alloc)/1024/1024)
-
-    return metrics, nil
-}
-
-
-func (bf *BlockFetcher) calculateAverageBlockTime(ctx context.Context) (float64, error) {
-    latestBlock, err := bf.Client.BlockByNumber(ctx, nil)
-    if err != nil {
-        return 0, err
+// networkLatency averages the rolling probe latency of the nodes that answered recently, in milliseconds.
+func (bf *BlockFetcher) networkLatency() float64 {
+    var total float64
+    var count int
+    for _, n := range bf.pool.Nodes() {
+        stats := n.LatencyStats()
+        if stats.Samples == 0 || stats.ErrorRate == 1 {
+            continue
+        }
+        total += stats.AvgMs
+        count++
     }
-
-    previousBlockNumber := new(big.Int).Sub(latestBlock.Number(), big.NewInt(25))
-    previousBlock, err := bf.Client.BlockByNumber(ctx, previousBlockNumber)
-    if err != nil {
-        return 0, err
+    if count == 0 {
+        return 0
     }
-
-    timeDiff := latestBlock.Time() - previousBlock.Time()
-    averageBlockTime := float64(timeDiff) / 100.0
-    return averageBlockTime / 60.0, nil
-}
-
-func (bf *BlockFetcher) calculateNetworkLatency() float64 {
-    //* (INCOMING: ping to the node) *//
-    /** FOR NOW its is mocked by 50ms **/
-    return 50.0
+    return total / float64(count)
 }
\ No newline at end of file
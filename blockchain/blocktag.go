@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "strconv"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/rpc"
+)
+
+/**
+  *  BlockNumberArg is a block number as sent by websocket clients: a JSON
+  *  number, a decimal or 0x-prefixed hex string, or one of the tags "latest",
+  *  "pending", "safe", "finalized" and "earliest".
+  */
+type BlockNumberArg rpc.BlockNumber
+
+const (
+    EarliestBlock  = BlockNumberArg(rpc.EarliestBlockNumber)
+    SafeBlock      = BlockNumberArg(rpc.SafeBlockNumber)
+    FinalizedBlock = BlockNumberArg(rpc.FinalizedBlockNumber)
+    LatestBlock    = BlockNumberArg(rpc.LatestBlockNumber)
+    PendingBlock   = BlockNumberArg(rpc.PendingBlockNumber)
+)
+
+func (b *BlockNumberArg) UnmarshalJSON(data []byte) error {
+    input := strings.TrimSpace(string(data))
+    if len(input) >= 2 && input[0] == '"' && input[len(input)-1] == '"' {
+        input = input[1 : len(input)-1]
+    }
+    if n, err := strconv.ParseInt(input, 10, 64); err == nil {
+        if n < 0 {
+            return fmt.Errorf("invalid block number %d", n)
+        }
+        *b = BlockNumberArg(n)
+        return nil
+    }
+    var bn rpc.BlockNumber
+    if err := json.Unmarshal([]byte(strconv.Quote(input)), &bn); err != nil {
+        return fmt.Errorf("invalid block number or tag %q", input)
+    }
+    *b = BlockNumberArg(bn)
+    return nil
+}
+
+// IsTag reports whether b is a named tag rather than a concrete number.
+func (b BlockNumberArg) IsTag() bool {
+    return b < 0
+}
+
+// BigInt converts b to the form accepted by ethclient, which maps negative values to tags.
+func (b BlockNumberArg) BigInt() *big.Int {
+    return big.NewInt(int64(b))
+}
+
+func (b BlockNumberArg) String() string {
+    return rpc.BlockNumber(b).String()
+}
+
+/**
+  *  BlockNumberOrHash selects a block either by number or tag, as accepted by
+  *  BlockNumberArg, or by a 32-byte hash. Exactly one of the fields is set.
+  */
+type BlockNumberOrHash struct {
+    Number *BlockNumberArg
+    Hash   *common.Hash
+}
+
+func (b *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
+    input := strings.TrimSpace(string(data))
+    if len(input) >= 2 && input[0] == '"' && input[len(input)-1] == '"' {
+        input = input[1 : len(input)-1]
+    }
+    if len(input) == 2+2*common.HashLength && strings.HasPrefix(input, "0x") {
+        var hash common.Hash
+        if err := hash.UnmarshalText([]byte(input)); err != nil {
+            return fmt.Errorf("invalid block hash %q", input)
+        }
+        *b = BlockNumberOrHash{Hash: &hash}
+        return nil
+    }
+    var number BlockNumberArg
+    if err := number.UnmarshalJSON(data); err != nil {
+        return err
+    }
+    *b = BlockNumberOrHash{Number: &number}
+    return nil
+}
+
+func (b BlockNumberOrHash) String() string {
+    if b.Hash != nil {
+        return b.Hash.Hex()
+    }
+    return b.Number.String()
+}
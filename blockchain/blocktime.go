@@ -0,0 +1,175 @@
+package blockchain
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "math/big"
+    "sort"
+    "sync"
+
+    "github.com/ethereum/go-ethereum/core/types"
+)
+
+const defaultBlockTimeWindow = 64
+
+/**
+  *  BlockTimeSummary describes the intervals between consecutive blocks of the
+  *  window, in seconds. Blocks is the number of blocks the figures are based
+  *  on; with fewer than two blocks every figure is zero.
+  */
+type BlockTimeSummary struct {
+    Blocks          int     `json:"blocks"`
+    MeanSeconds     float64 `json:"meanSeconds"`
+    MedianSeconds   float64 `json:"medianSeconds"`
+    StdDevSeconds   float64 `json:"stdDevSeconds"`
+    MinSeconds      float64 `json:"minSeconds"`
+    MaxSeconds      float64 `json:"maxSeconds"`
+    BlocksPerMinute float64 `json:"blocksPerMinute"`
+}
+
+type blockStamp struct {
+    number uint64
+    time   uint64
+}
+
+/**
+  *  BlockTimeStats keeps the timestamps of the most recent consecutive
+  *  canonical blocks. Headers that replace blocks already in the window, as
+  *  after a reorg, drop the replaced blocks; a gap in the numbers restarts
+  *  the window.
+  */
+type BlockTimeStats struct {
+    mu     sync.Mutex
+    size   int
+    blocks []blockStamp
+}
+
+func NewBlockTimeStats(size int) *BlockTimeStats {
+    if size < 2 {
+        size = 2
+    }
+    return &BlockTimeStats{size: size}
+}
+
+// Add appends a canonical header to the window.
+func (s *BlockTimeStats) Add(header *types.Header) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.add(blockStamp{number: header.Number.Uint64(), time: header.Time})
+}
+
+func (s *BlockTimeStats) add(b blockStamp) {
+    for len(s.blocks) > 0 && s.blocks[len(s.blocks)-1].number >= b.number {
+        s.blocks = s.blocks[:len(s.blocks)-1]
+    }
+    if len(s.blocks) > 0 && s.blocks[len(s.blocks)-1].number+1 != b.number {
+        s.blocks = s.blocks[:0]
+    }
+    s.blocks = append(s.blocks, b)
+    if len(s.blocks) > s.size {
+        s.blocks = append(s.blocks[:0], s.blocks[len(s.blocks)-s.size:]...)
+    }
+}
+
+func (s *BlockTimeStats) Len() int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return len(s.blocks)
+}
+
+func (s *BlockTimeStats) Summary() BlockTimeSummary {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    summary := BlockTimeSummary{Blocks: len(s.blocks)}
+    if len(s.blocks) < 2 {
+        return summary
+    }
+    intervals := make([]float64, len(s.blocks)-1)
+    var sum float64
+    for i := 1; i < len(s.blocks); i++ {
+        // Timestamps are not guaranteed to increase strictly; clamp rather than wrap.
+        var interval float64
+        if s.blocks[i].time > s.blocks[i-1].time {
+            interval = float64(s.blocks[i].time - s.blocks[i-1].time)
+        }
+        intervals[i-1] = interval
+        sum += interval
+    }
+    mean := sum / float64(len(intervals))
+    var variance float64
+    for _, interval := range intervals {
+        variance += (interval - mean) * (interval - mean)
+    }
+    variance /= float64(len(intervals))
+
+    sort.Float64s(intervals)
+    median := intervals[len(intervals)/2]
+    if len(intervals)%2 == 0 {
+        median = (intervals[len(intervals)/2-1] + intervals[len(intervals)/2]) / 2
+    }
+
+    summary.MeanSeconds = mean
+    summary.MedianSeconds = median
+    summary.StdDevSeconds = math.Sqrt(variance)
+    summary.MinSeconds = intervals[0]
+    summary.MaxSeconds = intervals[len(intervals)-1]
+    if mean > 0 {
+        summary.BlocksPerMinute = 60 / mean
+    }
+    return summary
+}
+
+// ObserveHead feeds a canonical head into the block time statistics and the block cache.
+func (bf *BlockFetcher) ObserveHead(header *types.Header) {
+    bf.blockTimes.Add(header)
+    bf.invalidateBlocks(header)
+}
+
+/**
+  *  BlockTimes returns the block time statistics. Until the head stream has
+  *  delivered two blocks, the window is seeded with the most recent headers,
+  *  stopping at genesis on chains shorter than the window.
+  */
+func (bf *BlockFetcher) BlockTimes(ctx context.Context) (BlockTimeSummary, error) {
+    if bf.blockTimes.Len() < 2 {
+        if err := bf.seedBlockTimes(ctx); err != nil {
+            return BlockTimeSummary{}, err
+        }
+    }
+    return bf.blockTimes.Summary(), nil
+}
+
+func (bf *BlockFetcher) seedBlockTimes(ctx context.Context) error {
+    head, err := bf.HeaderByNumber(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("failed to get latest block header: %v", err)
+    }
+    number := head.Number.Uint64()
+    count := uint64(bf.config.BlockTimeWindow)
+    if count > number+1 {
+        count = number + 1
+    }
+
+    stamps := make([]blockStamp, count)
+    stamps[count-1] = blockStamp{number: number, time: head.Time}
+    for i := uint64(1); i < count; i++ {
+        header, err := bf.HeaderByNumber(ctx, new(big.Int).SetUint64(number-i))
+        if err != nil {
+            return fmt.Errorf("failed to get block header %d: %v", number-i, err)
+        }
+        stamps[count-1-i] = blockStamp{number: number - i, time: header.Time}
+    }
+
+    bf.blockTimes.mu.Lock()
+    defer bf.blockTimes.mu.Unlock()
+    // Heads delivered while seeding are newer; keep them.
+    if len(bf.blockTimes.blocks) >= 2 {
+        return nil
+    }
+    for _, stamp := range stamps {
+        bf.blockTimes.add(stamp)
+    }
+    return nil
+}
@@ -0,0 +1,78 @@
+package blockchain
+
+import (
+    "math/big"
+    "testing"
+
+    "github.com/ethereum/go-ethereum/core/types"
+)
+
+// addBlocks adds consecutive headers starting at number with the given timestamps.
+func addBlocks(s *BlockTimeStats, number uint64, times ...uint64) {
+    for i, time := range times {
+        s.Add(&types.Header{Number: new(big.Int).SetUint64(number + uint64(i)), Time: time})
+    }
+}
+
+func TestBlockTimeStatsSlidingWindow(t *testing.T) {
+    s := NewBlockTimeStats(4)
+    addBlocks(s, 1, 100, 110, 112, 115, 119, 125)
+
+    // Blocks 3 to 6 remain, with intervals 3, 4 and 6.
+    want := BlockTimeSummary{
+        Blocks:          4,
+        MeanSeconds:     13.0 / 3,
+        MedianSeconds:   4,
+        StdDevSeconds:   1.247219128924647,
+        MinSeconds:      3,
+        MaxSeconds:      6,
+        BlocksPerMinute: 60 / (13.0 / 3),
+    }
+    if got := s.Summary(); got != want {
+        t.Fatalf("summary %+v, want %+v", got, want)
+    }
+}
+
+func TestBlockTimeStatsReorg(t *testing.T) {
+    s := NewBlockTimeStats(8)
+    addBlocks(s, 1, 100, 102, 104, 106, 108)
+
+    // A reorg replaces blocks 4 and 5 with a slower pair; block 4 arrives again first.
+    addBlocks(s, 4, 110)
+    if got := s.Len(); got != 4 {
+        t.Fatalf("window holds %d blocks after the reorg, want 4", got)
+    }
+    addBlocks(s, 5, 116)
+    got := s.Summary()
+    if got.Blocks != 5 || got.MinSeconds != 2 || got.MaxSeconds != 6 || got.MeanSeconds != 4 || got.MedianSeconds != 4 {
+        t.Fatalf("summary %+v, want 5 blocks with intervals 2, 2, 6 and 6", got)
+    }
+}
+
+func TestBlockTimeStatsGapRestartsWindow(t *testing.T) {
+    s := NewBlockTimeStats(8)
+    addBlocks(s, 1, 100, 102, 104)
+    addBlocks(s, 10, 200, 205)
+
+    got := s.Summary()
+    if got.Blocks != 2 || got.MeanSeconds != 5 {
+        t.Fatalf("summary %+v, want 2 blocks 5 seconds apart", got)
+    }
+}
+
+func TestBlockTimeStatsShortWindow(t *testing.T) {
+    s := NewBlockTimeStats(8)
+    if got := s.Summary(); got != (BlockTimeSummary{}) {
+        t.Fatalf("empty window summary %+v, want zeros", got)
+    }
+    addBlocks(s, 0, 100)
+    if got := s.Summary(); got != (BlockTimeSummary{Blocks: 1}) {
+        t.Fatalf("single block summary %+v, want zeros", got)
+    }
+
+    // A timestamp that goes backwards counts as a zero interval instead of wrapping around.
+    addBlocks(s, 1, 99)
+    if got := s.Summary(); got.MaxSeconds != 0 || got.BlocksPerMinute != 0 {
+        t.Fatalf("summary %+v, want zero intervals", got)
+    }
+}
@@ -0,0 +1,150 @@
+package blockchain
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math/big"
+    "strings"
+    "sync"
+
+    "github.com/ethereum/go-ethereum/core/types"
+)
+
+// maxNonceGap is how far above the sender's pending nonce a submitted nonce may be.
+const maxNonceGap = 64
+
+var ErrInvalidTransaction = errors.New("invalid transaction")
+
+// NodeSubmission is the answer of one node to a broadcast transaction.
+type NodeSubmission struct {
+    URL      string `json:"url"`
+    Accepted bool   `json:"accepted"`
+    Error    string `json:"error,omitempty"`
+}
+
+/**
+  *  SubmissionResult reports a broadcast transaction. Accepted is true when
+  *  at least one node took it into its pool.
+  */
+type SubmissionResult struct {
+    Hash     string           `json:"hash"`
+    From     string           `json:"from"`
+    Nonce    uint64           `json:"nonce"`
+    Accepted bool             `json:"accepted"`
+    Nodes    []NodeSubmission `json:"nodes"`
+}
+
+/**
+  *  SendRawTransaction decodes a signed transaction, checks it locally and
+  *  broadcasts it to every healthy node. It is rejected with
+  *  ErrInvalidTransaction when it cannot be decoded, is not replay protected,
+  *  targets another chain, carries an invalid signature, or has a nonce below
+  *  the sender's mined nonce or more than maxNonceGap above its pending one.
+  *  A node that already knows the transaction counts as accepting it.
+  */
+func (bf *BlockFetcher) SendRawTransaction(ctx context.Context, raw []byte) (*SubmissionResult, error) {
+    tx := new(types.Transaction)
+    if err := tx.UnmarshalBinary(raw); err != nil {
+        return nil, fmt.Errorf("%w: failed to decode: %v", ErrInvalidTransaction, err)
+    }
+    if !tx.Protected() {
+        return nil, fmt.Errorf("%w: transaction is not replay protected (EIP-155)", ErrInvalidTransaction)
+    }
+
+    chainID, err := bf.ChainID(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if tx.ChainId().Cmp(chainID) != 0 {
+        return nil, fmt.Errorf("%w: chain id %s does not match the network chain id %s", ErrInvalidTransaction, tx.ChainId(), chainID)
+    }
+    from, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+    if err != nil {
+        return nil, fmt.Errorf("%w: invalid signature: %v", ErrInvalidTransaction, err)
+    }
+
+    var mined, pending uint64
+    err = bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        if mined, err = n.Client.NonceAt(ctx, from, nil); err != nil {
+            return err
+        }
+        pending, err = n.Client.PendingNonceAt(ctx, from)
+        return err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch nonce of %s: %v", from.Hex(), err)
+    }
+    if tx.Nonce() < mined {
+        return nil, fmt.Errorf("%w: nonce %d is below the account nonce %d", ErrInvalidTransaction, tx.Nonce(), mined)
+    }
+    if tx.Nonce() > pending+maxNonceGap {
+        return nil, fmt.Errorf("%w: nonce %d is more than %d above the pending nonce %d", ErrInvalidTransaction, tx.Nonce(), maxNonceGap, pending)
+    }
+
+    var nodes []*Node
+    for _, n := range bf.pool.connectedNodes() {
+        if n.Healthy() {
+            nodes = append(nodes, n)
+        }
+    }
+    if len(nodes) == 0 {
+        return nil, ErrNoHealthyNodes
+    }
+
+    result := &SubmissionResult{
+        Hash:  tx.Hash().Hex(),
+        From:  from.Hex(),
+        Nonce: tx.Nonce(),
+        Nodes: make([]NodeSubmission, len(nodes)),
+    }
+    var wg sync.WaitGroup
+    for i, n := range nodes {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            submission := NodeSubmission{URL: n.URL, Accepted: true}
+            if err := n.Client.SendTransaction(ctx, tx); err != nil && !isKnownTransactionError(err) {
+                submission.Accepted = false
+                submission.Error = err.Error()
+            }
+            result.Nodes[i] = submission
+        }()
+    }
+    wg.Wait()
+
+    for _, submission := range result.Nodes {
+        if submission.Accepted {
+            result.Accepted = true
+            break
+        }
+    }
+    return result, nil
+}
+
+// ChainID returns the chain id of the network, fetched once.
+func (bf *BlockFetcher) ChainID(ctx context.Context) (*big.Int, error) {
+    bf.chainIDMu.Lock()
+    defer bf.chainIDMu.Unlock()
+    if bf.chainID != nil {
+        return bf.chainID, nil
+    }
+    var chainID *big.Int
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        chainID, err = n.Client.ChainID(ctx)
+        return err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch chain id: %v", err)
+    }
+    bf.chainID = chainID
+    return chainID, nil
+}
+
+// isKnownTransactionError reports whether a node rejected a transaction only because it already has it.
+func isKnownTransactionError(err error) bool {
+    msg := strings.ToLower(err.Error())
+    return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
@@ -0,0 +1,152 @@
+package blockchain
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math/big"
+    "sync"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/lru"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "github.com/ethereum/go-ethereum/rlp"
+    "github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+    // cliqueExtraSeal is the length of the sealer signature at the end of a Clique header's extraData.
+    cliqueExtraSeal = crypto.SignatureLength
+    // cliqueSignerCacheSize matches the recent signatures cache of the Clique engine.
+    cliqueSignerCacheSize = 4096
+)
+
+var (
+    errMissingSeal       = errors.New("extra-data 65 byte signature suffix missing")
+    errUnsupportedHeader  = errors.New("header carries fields unknown to clique")
+)
+
+/**
+  *  cliqueAdapter recovers block signers from the seal in extraData, so
+  *  attributing a block costs no RPC call and works on nodes that do not
+  *  expose the clique namespace. Recovered signers are cached by block hash.
+  *  When rpcFallback is set, headers that cannot be recovered locally are
+  *  resolved with clique_getSigner. Only the signer set comes from the node.
+  */
+type cliqueAdapter struct {
+    pool        *NodePool
+    rpcFallback bool
+
+    mu      sync.Mutex
+    signers lru.BasicLRU[common.Hash, common.Address]
+}
+
+func newCliqueAdapter(pool *NodePool, rpcFallback bool) *cliqueAdapter {
+    return &cliqueAdapter{
+        pool:        pool,
+        rpcFallback: rpcFallback,
+        signers:     lru.NewBasicLRU[common.Hash, common.Address](cliqueSignerCacheSize),
+    }
+}
+
+func (a *cliqueAdapter) Name() string {
+    return ConsensusClique
+}
+
+func (a *cliqueAdapter) Producer(ctx context.Context, header *types.Header) (*BlockProducer, error) {
+    // The genesis block is not sealed.
+    if header.Number.Sign() == 0 {
+        return &BlockProducer{}, nil
+    }
+    hash := header.Hash()
+    a.mu.Lock()
+    signer, ok := a.signers.Get(hash)
+    a.mu.Unlock()
+    if ok {
+        return &BlockProducer{Address: signer}, nil
+    }
+
+    signer, err := recoverCliqueSigner(header)
+    if err != nil {
+        if !a.rpcFallback {
+            return nil, fmt.Errorf("%w: failed to recover signer of block %d: %v", errProducerUnavailable, header.Number, err)
+        }
+        signer, err = a.fetchSigner(ctx, hash)
+        var rpcErr rpc.Error
+        if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == rpcMethodNotFound {
+            return nil, fmt.Errorf("%w: failed to fetch signer of block %d: %v", errProducerUnavailable, header.Number, err)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("failed to fetch signer of block %d: %v", header.Number, err)
+        }
+    }
+    a.mu.Lock()
+    a.signers.Add(hash, signer)
+    a.mu.Unlock()
+    return &BlockProducer{Address: signer}, nil
+}
+
+func (a *cliqueAdapter) fetchSigner(ctx context.Context, hash common.Hash) (common.Address, error) {
+    var signer common.Address
+    err := a.pool.Do(ctx, func(n *Node) error {
+        return n.RPCClient.CallContext(ctx, &signer, "clique_getSigner", hash)
+    })
+    return signer, err
+}
+
+func (a *cliqueAdapter) Validators(ctx context.Context, number *big.Int) ([]common.Address, error) {
+    var signers []common.Address
+    err := a.pool.Do(ctx, func(n *Node) error {
+        return n.RPCClient.CallContext(ctx, &signers, "clique_getSigners", numberArg(number))
+    })
+    if err != nil {
+        return nil, err
+    }
+    return signers, nil
+}
+
+// recoverCliqueSigner ecrecovers the sealer of header from the signature in its extraData.
+func recoverCliqueSigner(header *types.Header) (common.Address, error) {
+    if len(header.Extra) < cliqueExtraSeal {
+        return common.Address{}, errMissingSeal
+    }
+    // Clique networks never activate Shanghai, so a header with later fields was not sealed by Clique.
+    if header.WithdrawalsHash != nil || header.ParentBeaconRoot != nil {
+        return common.Address{}, errUnsupportedHeader
+    }
+    signature := header.Extra[len(header.Extra)-cliqueExtraSeal:]
+    pubkey, err := crypto.Ecrecover(cliqueSealHash(header).Bytes(), signature)
+    if err != nil {
+        return common.Address{}, err
+    }
+    var signer common.Address
+    copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+    return signer, nil
+}
+
+// cliqueSealHash is the hash a Clique sealer signs: the RLP of the header without the seal.
+func cliqueSealHash(header *types.Header) common.Hash {
+    enc := []interface{}{
+        header.ParentHash,
+        header.UncleHash,
+        header.Coinbase,
+        header.Root,
+        header.TxHash,
+        header.ReceiptHash,
+        header.Bloom,
+        header.Difficulty,
+        header.Number,
+        header.GasLimit,
+        header.GasUsed,
+        header.Time,
+        header.Extra[:len(header.Extra)-cliqueExtraSeal],
+        header.MixDigest,
+        header.Nonce,
+    }
+    if header.BaseFee != nil {
+        enc = append(enc, header.BaseFee)
+    }
+    data, _ := rlp.EncodeToBytes(enc)
+    return crypto.Keccak256Hash(data)
+}
@@ -0,0 +1,173 @@
+package blockchain
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "errors"
+    "math/big"
+    "testing"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+)
+
+func testCliqueHeader(number int64) *types.Header {
+    return &types.Header{
+        ParentHash:  common.HexToHash("0x01"),
+        UncleHash:   types.EmptyUncleHash,
+        Root:        common.HexToHash("0x02"),
+        TxHash:      types.EmptyTxsHash,
+        ReceiptHash: types.EmptyReceiptsHash,
+        Difficulty:  big.NewInt(2),
+        Number:      big.NewInt(number),
+        GasLimit:    8_000_000,
+        GasUsed:     21_000,
+        Time:        1_700_000_000,
+        Extra:       make([]byte, cliqueExtraVanity+cliqueExtraSeal),
+    }
+}
+
+// sealCliqueHeader signs header with key the way a Clique sealer does.
+func sealCliqueHeader(t *testing.T, header *types.Header, key *ecdsa.PrivateKey) {
+    t.Helper()
+    sig, err := crypto.Sign(cliqueSealHash(header).Bytes(), key)
+    if err != nil {
+        t.Fatalf("failed to sign header: %v", err)
+    }
+    copy(header.Extra[len(header.Extra)-cliqueExtraSeal:], sig)
+}
+
+// Up to London, the seal hash is the hash of the header with the seal cut from extraData.
+func TestCliqueSealHashMatchesUnsealedHeaderHash(t *testing.T) {
+    legacy := testCliqueHeader(5)
+    london := testCliqueHeader(6)
+    london.BaseFee = big.NewInt(7)
+    for _, header := range []*types.Header{legacy, london} {
+        unsealed := types.CopyHeader(header)
+        unsealed.Extra = unsealed.Extra[:len(unsealed.Extra)-cliqueExtraSeal]
+        if got, want := cliqueSealHash(header), unsealed.Hash(); got != want {
+            t.Errorf("block %d: seal hash %s, want %s", header.Number, got.Hex(), want.Hex())
+        }
+    }
+}
+
+func TestRecoverCliqueSigner(t *testing.T) {
+    key, err := crypto.GenerateKey()
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := crypto.PubkeyToAddress(key.PublicKey)
+
+    withBaseFee := testCliqueHeader(10)
+    withBaseFee.BaseFee = big.NewInt(1_000_000_000)
+    withSigners := testCliqueHeader(30000)
+    withSigners.Extra = make([]byte, cliqueExtraVanity+2*common.AddressLength+cliqueExtraSeal)
+
+    tests := []struct {
+        name   string
+        header *types.Header
+    }{
+        {"legacy", testCliqueHeader(10)},
+        {"base fee", withBaseFee},
+        {"checkpoint", withSigners},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            sealCliqueHeader(t, tt.header, key)
+            got, err := recoverCliqueSigner(tt.header)
+            if err != nil {
+                t.Fatalf("recoverCliqueSigner: %v", err)
+            }
+            if got != want {
+                t.Fatalf("recovered %s, want %s", got.Hex(), want.Hex())
+            }
+        })
+    }
+}
+
+func TestRecoverCliqueSignerTamperedHeader(t *testing.T) {
+    key, _ := crypto.GenerateKey()
+    header := testCliqueHeader(10)
+    header.BaseFee = big.NewInt(1_000_000_000)
+    sealCliqueHeader(t, header, key)
+
+    // The base fee is part of the sealed fields, so changing it changes the recovered signer.
+    header.BaseFee = big.NewInt(2_000_000_000)
+    got, err := recoverCliqueSigner(header)
+    if err == nil && got == crypto.PubkeyToAddress(key.PublicKey) {
+        t.Fatal("tampered header recovered to the original signer")
+    }
+}
+
+func TestRecoverCliqueSignerRejectsHeaders(t *testing.T) {
+    short := testCliqueHeader(10)
+    short.Extra = make([]byte, cliqueExtraSeal-1)
+    shanghai := testCliqueHeader(10)
+    shanghai.WithdrawalsHash = &types.EmptyWithdrawalsHash
+
+    tests := []struct {
+        name   string
+        header *types.Header
+        want   error
+    }{
+        {"extra too short", short, errMissingSeal},
+        {"shanghai fields", shanghai, errUnsupportedHeader},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if _, err := recoverCliqueSigner(tt.header); !errors.Is(err, tt.want) {
+                t.Fatalf("got error %v, want %v", err, tt.want)
+            }
+        })
+    }
+}
+
+func TestCliqueProducerWithoutFallback(t *testing.T) {
+    header := testCliqueHeader(10)
+    header.Extra = make([]byte, cliqueExtraSeal-1)
+
+    // Without the RPC fallback no later attempt can do better, which lets the block cache keep the block.
+    _, err := newCliqueAdapter(nil, false).Producer(context.Background(), header)
+    if !errors.Is(err, errProducerUnavailable) {
+        t.Fatalf("got error %v, want %v", err, errProducerUnavailable)
+    }
+}
+
+func TestIsCliqueHeader(t *testing.T) {
+    key, _ := crypto.GenerateKey()
+    seal := func(h *types.Header) *types.Header {
+        sealCliqueHeader(t, h, key)
+        return h
+    }
+    powDifficulty := seal(testCliqueHeader(10))
+    powDifficulty.Difficulty = big.NewInt(131072)
+    mixDigest := testCliqueHeader(10)
+    mixDigest.MixDigest = common.HexToHash("0x01")
+    seal(mixDigest)
+    shortExtra := testCliqueHeader(10)
+    shortExtra.Extra = make([]byte, cliqueExtraSeal)
+    postMerge := testCliqueHeader(10)
+    postMerge.Difficulty = big.NewInt(0)
+
+    tests := []struct {
+        name   string
+        header *types.Header
+        want   bool
+    }{
+        {"sealed in turn", seal(testCliqueHeader(10)), true},
+        {"unsealed genesis", testCliqueHeader(0), true},
+        {"unsealed block", testCliqueHeader(10), false},
+        {"proof of work difficulty", powDifficulty, false},
+        {"mix digest set", mixDigest, false},
+        {"extra without vanity", shortExtra, false},
+        {"zero difficulty", postMerge, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := isCliqueHeader(tt.header); got != tt.want {
+                t.Fatalf("isCliqueHeader = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
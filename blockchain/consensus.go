@@ -0,0 +1,196 @@
+package blockchain
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+    ConsensusClique = "clique"
+    ConsensusIBFT   = "ibft"
+    ConsensusQBFT   = "qbft"
+    ConsensusEthash = "ethash"
+    ConsensusPoS    = "pos"
+)
+
+// ErrNoValidatorSet is returned by adapters of networks without a fixed validator set.
+var ErrNoValidatorSet = errors.New("consensus engine has no validator set")
+
+// errProducerUnavailable marks producer lookups that cannot succeed with the current configuration, unlike transient failures.
+var errProducerUnavailable = errors.New("block producer cannot be resolved with this configuration")
+
+/**
+  *  ConsensusAdapter attributes blocks to the validator that produced them and
+  *  lists the validator set. Each consensus engine stores this information
+  *  differently, so the BlockFetcher delegates to the adapter matching the
+  *  network, either configured or detected from the node.
+  */
+type ConsensusAdapter interface {
+    Name() string
+    // Producer returns the validator, signer or miner credited with header.
+    Producer(ctx context.Context, header *types.Header) (*BlockProducer, error)
+    // Validators returns the validator set at block number, or at the head when number is nil.
+    Validators(ctx context.Context, number *big.Int) ([]common.Address, error)
+}
+
+// BlockProducer is the author of a block. Proposer is only set post-merge with a beacon node configured.
+type BlockProducer struct {
+    Address  common.Address
+    Proposer *BeaconProposer
+}
+
+// ValidateConsensus reports whether name selects a known consensus adapter. Empty means auto-detect.
+func ValidateConsensus(name string) error {
+    switch name {
+    case "", ConsensusClique, ConsensusIBFT, ConsensusQBFT, ConsensusEthash, ConsensusPoS:
+        return nil
+    default:
+        return fmt.Errorf("unknown consensus engine %q", name)
+    }
+}
+
+func newConsensusAdapter(name string, pool *NodePool, config FetcherConfig) (ConsensusAdapter, error) {
+    switch name {
+    case ConsensusClique:
+        return newCliqueAdapter(pool, config.CliqueRPCFallback), nil
+    case ConsensusIBFT:
+        return newIBFTAdapter(pool), nil
+    case ConsensusQBFT:
+        return newQBFTAdapter(pool), nil
+    case ConsensusEthash:
+        return ethashAdapter{}, nil
+    case ConsensusPoS:
+        return newPoSAdapter(config.BeaconURL), nil
+    default:
+        return nil, ValidateConsensus(name)
+    }
+}
+
+/**
+  *  detectConsensus probes the namespaces of the proof-of-authority engines.
+  *  Clique nodes that do not expose the clique namespace are recognised by
+  *  the shape of the head header. Otherwise proof of work is told from proof
+  *  of stake by the difficulty of the head, which is zero after the merge.
+  */
+func detectConsensus(ctx context.Context, pool *NodePool) (string, error) {
+    probes := []struct {
+        name   string
+        method string
+    }{
+        {ConsensusClique, "clique_getSigners"},
+        {ConsensusQBFT, "qbft_getValidatorsByBlockNumber"},
+        {ConsensusIBFT, "istanbul_getValidators"},
+    }
+    for _, probe := range probes {
+        var validators []common.Address
+        err := pool.Do(ctx, func(n *Node) error {
+            return n.RPCClient.CallContext(ctx, &validators, probe.method, "latest")
+        })
+        if err == nil {
+            return probe.name, nil
+        }
+        var rpcErr rpc.Error
+        if !errors.As(err, &rpcErr) {
+            return "", fmt.Errorf("failed to probe %s: %v", probe.method, err)
+        }
+    }
+
+    var header *types.Header
+    err := pool.Do(ctx, func(n *Node) error {
+        var err error
+        header, err = n.Client.HeaderByNumber(ctx, nil)
+        return err
+    })
+    if err != nil {
+        return "", fmt.Errorf("failed to fetch latest header: %v", err)
+    }
+    if isCliqueHeader(header) {
+        return ConsensusClique, nil
+    }
+    if header.Difficulty == nil || header.Difficulty.Sign() == 0 {
+        return ConsensusPoS, nil
+    }
+    return ConsensusEthash, nil
+}
+
+/**
+  *  isCliqueHeader reports whether header looks sealed by Clique: a vanity
+  *  prefix and a seal in extraData, a difficulty of 1 (out of turn) or 2 (in
+  *  turn) and an empty mix digest. Apart from the unsealed genesis block, its
+  *  seal must also recover to a signer.
+  */
+func isCliqueHeader(header *types.Header) bool {
+    if len(header.Extra) < cliqueExtraVanity+cliqueExtraSeal || header.MixDigest != (common.Hash{}) {
+        return false
+    }
+    if header.Difficulty == nil || !header.Difficulty.IsInt64() {
+        return false
+    }
+    if d := header.Difficulty.Int64(); d != 1 && d != 2 {
+        return false
+    }
+    if header.Number.Sign() == 0 {
+        return true
+    }
+    _, err := recoverCliqueSigner(header)
+    return err == nil
+}
+
+// consensus returns the configured adapter, detecting the engine on first use.
+func (bf *BlockFetcher) consensus(ctx context.Context) (ConsensusAdapter, error) {
+    bf.consensusMu.Lock()
+    defer bf.consensusMu.Unlock()
+
+    if bf.consensusAdapter != nil {
+        return bf.consensusAdapter, nil
+    }
+    name := bf.config.Consensus
+    if name == "" {
+        detected, err := detectConsensus(ctx, bf.pool)
+        if err != nil {
+            return nil, fmt.Errorf("failed to detect consensus engine: %v", err)
+        }
+        log.Printf("Detected %s consensus", detected)
+        name = detected
+    }
+    adapter, err := newConsensusAdapter(name, bf.pool, bf.config)
+    if err != nil {
+        return nil, err
+    }
+    bf.consensusAdapter = adapter
+    return adapter, nil
+}
+
+// numberArg encodes a block number for JSON-RPC, nil meaning the latest block.
+func numberArg(number *big.Int) string {
+    if number == nil {
+        return "latest"
+    }
+    return hexutil.EncodeBig(number)
+}
+
+/****************************************** ethashAdapter ******************************************/
+/***************************************************************************************************/
+
+// ethashAdapter credits proof-of-work blocks to their coinbase.
+type ethashAdapter struct{}
+
+func (ethashAdapter) Name() string {
+    return ConsensusEthash
+}
+
+func (ethashAdapter) Producer(ctx context.Context, header *types.Header) (*BlockProducer, error) {
+    return &BlockProducer{Address: header.Coinbase}, nil
+}
+
+func (ethashAdapter) Validators(ctx context.Context, number *big.Int) ([]common.Address, error) {
+    return nil, ErrNoValidatorSet
+}
@@ -0,0 +1,136 @@
+package blockchain
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/rpc"
+)
+
+// receiptBatchSize bounds the number of receipt lookups sent in one JSON-RPC batch.
+const receiptBatchSize = 200
+
+/**
+  *  errReceiptsMissing reports a node that answered but did not have every
+  *  receipt of a block, for example while its transaction index is still being
+  *  built. It is a data error, not a node failure, so it never fails over.
+  */
+var errReceiptsMissing = errors.New("receipts not available")
+
+// blockFees splits the fees paid in a block, all in wei. Priority is Total minus Burnt.
+type blockFees struct {
+    Total    *big.Int
+    Burnt    *big.Int
+    Priority *big.Int
+}
+
+/**
+  *  fetchReceipts loads the receipts of every transaction in block with one
+  *  eth_getBlockReceipts call, falling back to batched
+  *  eth_getTransactionReceipt calls on nodes without it. Receipts are
+  *  returned in transaction order and must belong to block, so a reorg
+  *  between the block and the receipt lookups surfaces as an error instead
+  *  of mixed data. A null or short answer yields errReceiptsMissing.
+  */
+func (bf *BlockFetcher) fetchReceipts(ctx context.Context, block *types.Block) ([]*types.Receipt, error) {
+    txs := block.Transactions()
+    if len(txs) == 0 {
+        return nil, nil
+    }
+
+    var raw []json.RawMessage
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        raw = nil
+        err := n.RPCClient.CallContext(ctx, &raw, "eth_getBlockReceipts", block.Hash())
+        var rpcErr rpc.Error
+        if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == rpcMethodNotFound {
+            raw, err = fetchReceiptsBatched(ctx, n, txs)
+        }
+        return err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch receipts of block %d: %v", block.NumberU64(), err)
+    }
+    if len(raw) != len(txs) {
+        return nil, fmt.Errorf("%w: block %d has %d transactions, node returned %d receipts", errReceiptsMissing, block.NumberU64(), len(txs), len(raw))
+    }
+
+    receipts := make([]*types.Receipt, len(txs))
+    for i, data := range raw {
+        if len(data) == 0 || string(data) == "null" {
+            return nil, fmt.Errorf("%w: no receipt for transaction %s", errReceiptsMissing, txs[i].Hash().Hex())
+        }
+        receipt := new(types.Receipt)
+        if err := json.Unmarshal(data, receipt); err != nil {
+            return nil, fmt.Errorf("invalid receipt of transaction %s: %v", txs[i].Hash().Hex(), err)
+        }
+        if receipt.TxHash != txs[i].Hash() || receipt.BlockHash != block.Hash() {
+            return nil, fmt.Errorf("receipt of transaction %s does not belong to block %s", txs[i].Hash().Hex(), block.Hash().Hex())
+        }
+        receipts[i] = receipt
+    }
+    return receipts, nil
+}
+
+// fetchReceiptsBatched looks the receipts up one transaction at a time, in batches. Unknown receipts come back as null.
+func fetchReceiptsBatched(ctx context.Context, n *Node, txs types.Transactions) ([]json.RawMessage, error) {
+    raw := make([]json.RawMessage, len(txs))
+    for start := 0; start < len(txs); start += receiptBatchSize {
+        end := min(start+receiptBatchSize, len(txs))
+        batch := make([]rpc.BatchElem, end-start)
+        for i := range batch {
+            batch[i] = rpc.BatchElem{
+                Method: "eth_getTransactionReceipt",
+                Args:   []interface{}{txs[start+i].Hash()},
+                Result: &raw[start+i],
+            }
+        }
+        if err := n.RPCClient.BatchCallContext(ctx, batch); err != nil {
+            return nil, err
+        }
+        for _, elem := range batch {
+            if elem.Error != nil {
+                return nil, elem.Error
+            }
+        }
+    }
+    return raw, nil
+}
+
+/**
+  *  calculateFees charges every transaction gasUsed * effectiveGasPrice. The
+  *  base fee part of that price is burnt; the rest goes to the block producer.
+  *  Blocks before London have no base fee, so nothing is burnt.
+  */
+func calculateFees(block *types.Block, receipts []*types.Receipt) blockFees {
+    fees := blockFees{Total: big.NewInt(0), Burnt: big.NewInt(0)}
+    baseFee := block.BaseFee()
+    for i, tx := range block.Transactions() {
+        gasUsed := new(big.Int).SetUint64(receipts[i].GasUsed)
+        fees.Total.Add(fees.Total, new(big.Int).Mul(gasUsed, effectiveGasPrice(tx, receipts[i], baseFee)))
+        if baseFee != nil {
+            fees.Burnt.Add(fees.Burnt, new(big.Int).Mul(gasUsed, baseFee))
+        }
+    }
+    fees.Priority = new(big.Int).Sub(fees.Total, fees.Burnt)
+    return fees
+}
+
+// effectiveGasPrice prefers the price reported by the receipt and derives it for nodes that omit it.
+func effectiveGasPrice(tx *types.Transaction, receipt *types.Receipt, baseFee *big.Int) *big.Int {
+    if receipt.EffectiveGasPrice != nil && receipt.EffectiveGasPrice.Sign() > 0 {
+        return receipt.EffectiveGasPrice
+    }
+    if baseFee == nil {
+        return tx.GasPrice()
+    }
+    tip, err := tx.EffectiveGasTip(baseFee)
+    if err != nil {
+        return tx.GasPrice()
+    }
+    return tip.Add(tip, baseFee)
+}
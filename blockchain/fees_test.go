@@ -0,0 +1,81 @@
+package blockchain
+
+import (
+    "math/big"
+    "testing"
+
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/params"
+)
+
+func gwei(n int64) *big.Int {
+    return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.GWei))
+}
+
+func legacyTx(gasPrice *big.Int) *types.Transaction {
+    return types.NewTx(&types.LegacyTx{Gas: 100_000, GasPrice: gasPrice})
+}
+
+func dynamicFeeTx(tip, feeCap *big.Int) *types.Transaction {
+    return types.NewTx(&types.DynamicFeeTx{Gas: 100_000, GasTipCap: tip, GasFeeCap: feeCap})
+}
+
+// feeReceipt is the receipt of a transaction that used gasUsed gas; a nil price leaves effectiveGasPrice out, as older nodes do.
+func feeReceipt(gasUsed uint64, price *big.Int) *types.Receipt {
+    return &types.Receipt{GasUsed: gasUsed, EffectiveGasPrice: price}
+}
+
+func TestCalculateFees(t *testing.T) {
+    tests := []struct {
+        name     string
+        baseFee  *big.Int
+        txs      []*types.Transaction
+        receipts []*types.Receipt
+        total    *big.Int
+        burnt    *big.Int
+    }{
+        {
+            name:     "no transactions",
+            baseFee:  gwei(10),
+            total:    big.NewInt(0),
+            burnt:    big.NewInt(0),
+        },
+        {
+            name:     "before London",
+            txs:      []*types.Transaction{legacyTx(gwei(20)), legacyTx(gwei(5))},
+            receipts: []*types.Receipt{feeReceipt(21_000, nil), feeReceipt(50_000, gwei(5))},
+            total:    new(big.Int).Add(new(big.Int).Mul(big.NewInt(21_000), gwei(20)), new(big.Int).Mul(big.NewInt(50_000), gwei(5))),
+            burnt:    big.NewInt(0),
+        },
+        {
+            name:     "after London, price from the receipt",
+            baseFee:  gwei(10),
+            txs:      []*types.Transaction{dynamicFeeTx(gwei(2), gwei(30))},
+            receipts: []*types.Receipt{feeReceipt(50_000, gwei(12))},
+            total:    new(big.Int).Mul(big.NewInt(50_000), gwei(12)),
+            burnt:    new(big.Int).Mul(big.NewInt(50_000), gwei(10)),
+        },
+        {
+            // The tip is capped by feeCap - baseFee, and a legacy transaction tips its price above the base fee.
+            name:     "after London, price derived from the transaction",
+            baseFee:  gwei(10),
+            txs:      []*types.Transaction{dynamicFeeTx(gwei(2), gwei(11)), legacyTx(gwei(15))},
+            receipts: []*types.Receipt{feeReceipt(21_000, nil), feeReceipt(21_000, nil)},
+            total:    new(big.Int).Mul(big.NewInt(21_000), gwei(11+15)),
+            burnt:    new(big.Int).Mul(big.NewInt(2*21_000), gwei(10)),
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            header := &types.Header{Number: big.NewInt(1), BaseFee: tt.baseFee}
+            block := types.NewBlockWithHeader(header).WithBody(types.Body{Transactions: tt.txs})
+
+            fees := calculateFees(block, tt.receipts)
+            priority := new(big.Int).Sub(tt.total, tt.burnt)
+            if fees.Total.Cmp(tt.total) != 0 || fees.Burnt.Cmp(tt.burnt) != 0 || fees.Priority.Cmp(priority) != 0 {
+                t.Fatalf("fees total %s burnt %s priority %s, want %s, %s and %s",
+                    fees.Total, fees.Burnt, fees.Priority, tt.total, tt.burnt, priority)
+            }
+        })
+    }
+}
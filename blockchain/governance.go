@@ -0,0 +1,199 @@
+package blockchain
+
+import (
+    "context"
+    "fmt"
+    "math/big"
+    "sync"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+    defaultCliqueEpoch = 30000
+    cliqueExtraVanity  = 32
+)
+
+/**
+  *  SignerSetChange reports the difference between the signer lists of two
+  *  consecutive Clique checkpoint blocks. Votes take effect as soon as they
+  *  pass, and every checkpoint records the resulting signer set in its
+  *  extraData.
+  */
+type SignerSetChange struct {
+    Block   uint64           `json:"block"`
+    Hash    string           `json:"hash"`
+    Signers []common.Address `json:"signers"`
+    Added   []common.Address `json:"added"`
+    Removed []common.Address `json:"removed"`
+}
+
+// GetValidatorsAt returns the validator set at block and the number the block resolved to.
+func (bf *BlockFetcher) GetValidatorsAt(ctx context.Context, block BlockNumberArg) ([]common.Address, uint64, error) {
+    adapter, err := bf.consensus(ctx)
+    if err != nil {
+        return nil, 0, err
+    }
+    number, err := bf.resolveBlockNumber(ctx, block)
+    if err != nil {
+        return nil, 0, err
+    }
+    validators, err := adapter.Validators(ctx, new(big.Int).SetUint64(number))
+    if err != nil {
+        return nil, 0, fmt.Errorf("failed to fetch validators at block %d: %w", number, err)
+    }
+    return validators, number, nil
+}
+
+/**
+  *  SignerSetChange compares the signer list of a Clique checkpoint header with
+  *  the previous checkpoint. It returns nil for other headers, for unchanged
+  *  signer sets and on networks that do not run Clique.
+  */
+func (bf *BlockFetcher) SignerSetChange(ctx context.Context, header *types.Header) (*SignerSetChange, error) {
+    adapter, err := bf.consensus(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if adapter.Name() != ConsensusClique {
+        return nil, nil
+    }
+    number := header.Number.Uint64()
+    if number == 0 || number%bf.config.CliqueEpoch != 0 {
+        return nil, nil
+    }
+
+    signers, err := checkpointSigners(header)
+    if err != nil {
+        return nil, fmt.Errorf("invalid checkpoint %d: %v", number, err)
+    }
+    previousHeader, err := bf.HeaderByNumber(ctx, new(big.Int).SetUint64(number-bf.config.CliqueEpoch))
+    if err != nil {
+        return nil, err
+    }
+    previous, err := checkpointSigners(previousHeader)
+    if err != nil {
+        return nil, fmt.Errorf("invalid checkpoint %d: %v", previousHeader.Number, err)
+    }
+
+    change := &SignerSetChange{
+        Block:   number,
+        Hash:    header.Hash().Hex(),
+        Signers: signers,
+        Added:   addressDiff(signers, previous),
+        Removed: addressDiff(previous, signers),
+    }
+    if len(change.Added) == 0 && len(change.Removed) == 0 {
+        return nil, nil
+    }
+    return change, nil
+}
+
+// checkpointSigners reads the signer list stored between the vanity and the seal of a checkpoint's extraData.
+func checkpointSigners(header *types.Header) ([]common.Address, error) {
+    if len(header.Extra) < cliqueExtraVanity+cliqueExtraSeal {
+        return nil, errMissingSeal
+    }
+    list := header.Extra[cliqueExtraVanity : len(header.Extra)-cliqueExtraSeal]
+    if len(list)%common.AddressLength != 0 {
+        return nil, fmt.Errorf("signer list of %d bytes is not a multiple of %d", len(list), common.AddressLength)
+    }
+    signers := make([]common.Address, len(list)/common.AddressLength)
+    for i := range signers {
+        copy(signers[i][:], list[i*common.AddressLength:])
+    }
+    return signers, nil
+}
+
+// addressDiff returns the addresses of a that are not in b.
+func addressDiff(a, b []common.Address) []common.Address {
+    seen := make(map[common.Address]bool, len(b))
+    for _, addr := range b {
+        seen[addr] = true
+    }
+    diff := []common.Address{}
+    for _, addr := range a {
+        if !seen[addr] {
+            diff = append(diff, addr)
+        }
+    }
+    return diff
+}
+
+/****************************************** MiningController governance methods ******************************************/
+/*************************************************************************************************************************/
+
+// NodeProposals is the set of pending proposals held by one node.
+type NodeProposals struct {
+    URL       string                  `json:"url"`
+    Proposals map[common.Address]bool `json:"proposals"`
+}
+
+/**
+  *  Clique proposals are local to each node: a vote is only cast when the node
+  *  that holds the proposal seals a block. GetProposals therefore reports the
+  *  proposals of every node, and Propose and Discard apply to all of them.
+  */
+func (mc *MiningController) GetProposals(ctx context.Context) ([]NodeProposals, error) {
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+
+    proposals := make([]NodeProposals, 0, len(mc.clients))
+    for i, client := range mc.clients {
+        var nodeProposals map[common.Address]bool
+        err := client.CallContext(ctx, &nodeProposals, "clique_proposals")
+        if err != nil {
+            return nil, fmt.Errorf("failed to fetch proposals from %s: %v", mc.urls[i], err)
+        }
+        if nodeProposals == nil {
+            nodeProposals = map[common.Address]bool{}
+        }
+        proposals = append(proposals, NodeProposals{URL: mc.urls[i], Proposals: nodeProposals})
+    }
+    return proposals, nil
+}
+
+// NodeResult is the outcome of a governance call on one node.
+type NodeResult struct {
+    URL     string `json:"url"`
+    Success bool   `json:"success"`
+    Error   string `json:"error,omitempty"`
+}
+
+/**
+  *  Propose makes every node vote to authorize (or, with authorize false, to
+  *  drop) address as a signer. Every node is called whatever the others
+  *  answer, and the outcome of each is returned in node order.
+  */
+func (mc *MiningController) Propose(ctx context.Context, address common.Address, authorize bool) []NodeResult {
+    return mc.callEach(ctx, "clique_propose", address, authorize)
+}
+
+// Discard withdraws the pending proposal about address from every node and returns the outcome of each, in node order.
+func (mc *MiningController) Discard(ctx context.Context, address common.Address) []NodeResult {
+    return mc.callEach(ctx, "clique_discard", address)
+}
+
+// callEach calls method on every node concurrently; a failing node does not keep the call from the others.
+func (mc *MiningController) callEach(ctx context.Context, method string, args ...interface{}) []NodeResult {
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+
+    results := make([]NodeResult, len(mc.clients))
+    var wg sync.WaitGroup
+    for i, client := range mc.clients {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            result := NodeResult{URL: mc.urls[i], Success: true}
+            if err := client.CallContext(ctx, nil, method, args...); err != nil {
+                result.Success = false
+                result.Error = err.Error()
+            }
+            results[i] = result
+        }()
+    }
+    wg.Wait()
+    return results
+}
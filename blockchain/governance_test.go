@@ -0,0 +1,107 @@
+package blockchain
+
+import (
+    "context"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/rpc"
+)
+
+// testCliqueAPI is the clique namespace of a fake signer node.
+type testCliqueAPI struct {
+    proposals map[common.Address]bool
+}
+
+func (api *testCliqueAPI) Propose(address common.Address, auth bool) {
+    api.proposals[address] = auth
+}
+
+func (api *testCliqueAPI) Discard(address common.Address) {
+    delete(api.proposals, address)
+}
+
+func (api *testCliqueAPI) Proposals() map[common.Address]bool {
+    return api.proposals
+}
+
+// newTestSigner serves api as the clique namespace of a node, or no clique namespace at all when api is nil.
+func newTestSigner(t *testing.T, api *testCliqueAPI) string {
+    t.Helper()
+    srv := rpc.NewServer()
+    if api != nil {
+        if err := srv.RegisterName("clique", api); err != nil {
+            t.Fatalf("failed to register fake clique API: %v", err)
+        }
+    }
+    hs := httptest.NewServer(srv)
+    t.Cleanup(func() {
+        hs.Close()
+        srv.Stop()
+    })
+    return hs.URL
+}
+
+func TestMiningControllerCallsEveryNode(t *testing.T) {
+    first := &testCliqueAPI{proposals: map[common.Address]bool{}}
+    last := &testCliqueAPI{proposals: map[common.Address]bool{}}
+    urls := []string{newTestSigner(t, first), newTestSigner(t, nil), newTestSigner(t, last)}
+    mc, err := NewMiningController(context.Background(), urls)
+    if err != nil {
+        t.Fatalf("NewMiningController: %v", err)
+    }
+    defer mc.Close()
+
+    address := common.HexToAddress("0x4b20993bc481177ec7e8f571cecae8a9e22c02db")
+    check := func(action string, results []NodeResult) {
+        t.Helper()
+        if len(results) != len(urls) {
+            t.Fatalf("%s: %d results for %d nodes", action, len(results), len(urls))
+        }
+        for i, result := range results {
+            if result.URL != urls[i] {
+                t.Fatalf("%s: result %d is for %s, want %s", action, i, result.URL, urls[i])
+            }
+            if want := i != 1; result.Success != want || (result.Error == "") != want {
+                t.Fatalf("%s: node %d reported %+v, want success %v", action, i, result, want)
+            }
+        }
+    }
+
+    // The node without the clique namespace fails; the nodes after it are still called.
+    check("propose", mc.Propose(context.Background(), address, true))
+    for _, api := range []*testCliqueAPI{first, last} {
+        if auth, ok := api.proposals[address]; !ok || !auth {
+            t.Fatalf("proposals %v, want %s authorized", api.proposals, address.Hex())
+        }
+    }
+
+    check("discard", mc.Discard(context.Background(), address))
+    for _, api := range []*testCliqueAPI{first, last} {
+        if len(api.proposals) != 0 {
+            t.Fatalf("proposals %v left after discard", api.proposals)
+        }
+    }
+}
+
+func TestMiningControllerGetProposals(t *testing.T) {
+    address := common.HexToAddress("0x4b20993bc481177ec7e8f571cecae8a9e22c02db")
+    apis := []*testCliqueAPI{{proposals: map[common.Address]bool{address: true}}, {proposals: map[common.Address]bool{}}}
+    urls := []string{newTestSigner(t, apis[0]), newTestSigner(t, apis[1])}
+    mc, err := NewMiningController(context.Background(), urls)
+    if err != nil {
+        t.Fatalf("NewMiningController: %v", err)
+    }
+    defer mc.Close()
+
+    proposals, err := mc.GetProposals(context.Background())
+    if err != nil {
+        t.Fatalf("GetProposals: %v", err)
+    }
+    want := []NodeProposals{{URL: urls[0], Proposals: apis[0].proposals}, {URL: urls[1], Proposals: apis[1].proposals}}
+    if !reflect.DeepEqual(proposals, want) {
+        t.Fatalf("proposals %+v, want %+v", proposals, want)
+    }
+}
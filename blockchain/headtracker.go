@@ -0,0 +1,353 @@
+package blockchain
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+    headBackoffMin      = time.Second
+    headBackoffMax      = 30 * time.Second
+    maxBackfillBlocks   = 128
+    canonicalWindowSize = 64
+)
+
+type BlockRef struct {
+    Number uint64 `json:"number"`
+    Hash   string `json:"hash"`
+}
+
+/**
+  *  Reorg describes a replacement of the tail of the canonical chain. Removed
+  *  and Added are ordered by ascending block number. CommonAncestor is nil when
+  *  the reorg is deeper than the tracked window.
+  */
+type Reorg struct {
+    Depth          int        `json:"depth"`
+    CommonAncestor *BlockRef  `json:"commonAncestor,omitempty"`
+    Removed        []BlockRef `json:"removed"`
+    Added          []BlockRef `json:"added"`
+}
+
+// Gap is a range of blocks, From to To inclusive, that the tracker skipped because it exceeded the backfill limit.
+type Gap struct {
+    From uint64 `json:"from"`
+    To   uint64 `json:"to"`
+}
+
+// HeadEvent carries either a new canonical header, a reorg notice or a gap notice.
+type HeadEvent struct {
+    Header *types.Header
+    Reorg  *Reorg
+    Gap    *Gap
+}
+
+/**
+  *  HeadTracker follows the chain head through the node pool. It reconnects
+  *  with exponential backoff whenever the subscription drops, backfills blocks
+  *  skipped while disconnected, and checks every head against a short window
+  *  of canonical headers. When a head does not extend the window, the tracker
+  *  walks back by parent hash to the common ancestor and emits a Reorg event
+  *  before the replacement headers. Gaps too large to backfill are announced
+  *  with a Gap event before the headers that follow them.
+  */
+type HeadTracker struct {
+    fetcher headSource
+    events  chan HeadEvent
+    window  *chainWindow
+    after   func(time.Duration) <-chan time.Time
+}
+
+// headSource is what the tracker reads heads from; the BlockFetcher outside of tests.
+type headSource interface {
+    SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+    HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+}
+
+func NewHeadTracker(fetcher *BlockFetcher) *HeadTracker {
+    return newHeadTracker(fetcher)
+}
+
+func newHeadTracker(source headSource) *HeadTracker {
+    return &HeadTracker{
+        fetcher: source,
+        events:  make(chan HeadEvent, 16),
+        window:  newChainWindow(canonicalWindowSize),
+        after:   time.After,
+    }
+}
+
+// Events returns the ordered event stream. It is closed when Run returns.
+func (t *HeadTracker) Events() <-chan HeadEvent {
+    return t.events
+}
+
+// Run follows the chain head until ctx is cancelled.
+func (t *HeadTracker) Run(ctx context.Context) {
+    defer close(t.events)
+
+    backoff := headBackoffMin
+    for {
+        delivered, err := t.follow(ctx)
+        if ctx.Err() != nil {
+            return
+        }
+        if delivered {
+            backoff = headBackoffMin
+        }
+        log.Printf("Head subscription lost: %v (reconnecting in %s)", err, backoff)
+
+        select {
+        case <-t.after(backoff):
+        case <-ctx.Done():
+            return
+        }
+        backoff *= 2
+        if backoff > headBackoffMax {
+            backoff = headBackoffMax
+        }
+    }
+}
+
+// follow subscribes once and delivers heads until the subscription fails.
+func (t *HeadTracker) follow(ctx context.Context) (bool, error) {
+    headers := make(chan *types.Header, 16)
+    sub, err := t.fetcher.SubscribeNewHead(ctx, headers)
+    if err != nil {
+        return false, err
+    }
+    defer sub.Unsubscribe()
+
+    delivered := false
+    for {
+        select {
+        case <-ctx.Done():
+            return delivered, ctx.Err()
+        case err := <-sub.Err():
+            return delivered, err
+        case header := <-headers:
+            if err := t.deliver(ctx, header); err != nil {
+                return delivered, err
+            }
+            delivered = true
+        }
+    }
+}
+
+/**
+  *  deliver connects header to the canonical window. It fetches missing
+  *  ancestors by parent hash until one matches the window, which covers both
+  *  gaps left by a reconnect and reorgs. Gaps larger than maxBackfillBlocks
+  *  restart the window from the most recent blocks, after a Gap event naming
+  *  the blocks that were skipped.
+  */
+func (t *HeadTracker) deliver(ctx context.Context, header *types.Header) error {
+    head := t.window.head()
+    if head == nil {
+        return t.emitHeaders(ctx, []*types.Header{header})
+    }
+    if t.window.contains(header) {
+        return nil
+    }
+
+    number := header.Number.Uint64()
+    headNumber := head.Number.Uint64()
+    if number > headNumber+maxBackfillBlocks {
+        log.Printf("Head gap of %d blocks exceeds backfill limit, restarting from block %d", number-headNumber-1, number-maxBackfillBlocks+1)
+        segment, err := t.ancestors(ctx, header, maxBackfillBlocks)
+        if err != nil {
+            return err
+        }
+        gap := &Gap{From: headNumber + 1, To: segment[0].Number.Uint64() - 1}
+        if err := t.emit(ctx, HeadEvent{Gap: gap}); err != nil {
+            return err
+        }
+        t.window.reset()
+        return t.emitHeaders(ctx, segment)
+    }
+
+    /**
+      *  Walk back until the parent of the oldest fetched header is the
+      *  canonical block at that height, or until we leave the window.
+      */
+    segment := []*types.Header{header}
+    var ancestor *types.Header
+    for {
+        oldest := segment[0]
+        if oldest.Number.Sign() == 0 {
+            break
+        }
+        parentNumber := oldest.Number.Uint64() - 1
+        if known := t.window.get(parentNumber); known != nil && known.Hash() == oldest.ParentHash {
+            ancestor = known
+            break
+        }
+        if parentNumber < t.window.lowest() {
+            break
+        }
+        parent, err := t.fetcher.HeaderByHash(ctx, oldest.ParentHash)
+        if err != nil {
+            return err
+        }
+        segment = append([]*types.Header{parent}, segment...)
+    }
+
+    removed := t.window.above(segment[0].Number.Uint64() - 1)
+    if len(removed) > 0 {
+        reorg := &Reorg{
+            Depth:   len(removed),
+            Removed: blockRefs(removed),
+            Added:   blockRefs(segment),
+        }
+        if ancestor != nil {
+            ref := blockRef(ancestor)
+            reorg.CommonAncestor = &ref
+            t.window.truncate(segment[0].Number.Uint64())
+        } else {
+            t.window.reset()
+        }
+        log.Printf("Chain reorganization of depth %d detected at block %d", reorg.Depth, segment[0].Number.Uint64())
+        if err := t.emit(ctx, HeadEvent{Reorg: reorg}); err != nil {
+            return err
+        }
+    } else if len(segment) > 1 {
+        log.Printf("Backfilling blocks %d to %d", segment[0].Number.Uint64(), number-1)
+    }
+    return t.emitHeaders(ctx, segment)
+}
+
+// ancestors returns header preceded by up to count-1 of its ancestors, oldest first.
+func (t *HeadTracker) ancestors(ctx context.Context, header *types.Header, count int) ([]*types.Header, error) {
+    segment := []*types.Header{header}
+    for len(segment) < count && segment[0].Number.Sign() > 0 {
+        parent, err := t.fetcher.HeaderByHash(ctx, segment[0].ParentHash)
+        if err != nil {
+            return nil, err
+        }
+        segment = append([]*types.Header{parent}, segment...)
+    }
+    return segment, nil
+}
+
+func (t *HeadTracker) emitHeaders(ctx context.Context, headers []*types.Header) error {
+    for _, header := range headers {
+        if err := t.emit(ctx, HeadEvent{Header: header}); err != nil {
+            return err
+        }
+        t.window.add(header)
+    }
+    return nil
+}
+
+func (t *HeadTracker) emit(ctx context.Context, ev HeadEvent) error {
+    select {
+    case t.events <- ev:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+func blockRef(header *types.Header) BlockRef {
+    return BlockRef{Number: header.Number.Uint64(), Hash: header.Hash().Hex()}
+}
+
+func blockRefs(headers []*types.Header) []BlockRef {
+    refs := make([]BlockRef, len(headers))
+    for i, header := range headers {
+        refs[i] = blockRef(header)
+    }
+    return refs
+}
+
+/****************************************** chainWindow ******************************************/
+/*************************************************************************************************/
+
+// chainWindow keeps the most recent canonical headers keyed by number.
+type chainWindow struct {
+    size    int
+    headers map[uint64]*types.Header
+    low     uint64
+    high    uint64
+}
+
+func newChainWindow(size int) *chainWindow {
+    return &chainWindow{
+        size:    size,
+        headers: make(map[uint64]*types.Header),
+    }
+}
+
+func (w *chainWindow) head() *types.Header {
+    if len(w.headers) == 0 {
+        return nil
+    }
+    return w.headers[w.high]
+}
+
+func (w *chainWindow) lowest() uint64 {
+    return w.low
+}
+
+func (w *chainWindow) get(number uint64) *types.Header {
+    return w.headers[number]
+}
+
+func (w *chainWindow) contains(header *types.Header) bool {
+    known := w.headers[header.Number.Uint64()]
+    return known != nil && known.Hash() == header.Hash()
+}
+
+// above returns the headers with a number greater than number, oldest first.
+func (w *chainWindow) above(number uint64) []*types.Header {
+    var headers []*types.Header
+    if len(w.headers) == 0 {
+        return headers
+    }
+    from := number + 1
+    if from < w.low {
+        from = w.low
+    }
+    for n := from; n <= w.high; n++ {
+        headers = append(headers, w.headers[n])
+    }
+    return headers
+}
+
+// truncate drops every header at or above number.
+func (w *chainWindow) truncate(number uint64) {
+    for n := range w.headers {
+        if n >= number {
+            delete(w.headers, n)
+        }
+    }
+    if len(w.headers) == 0 {
+        return
+    }
+    w.high = number - 1
+}
+
+// add appends header as the new head and evicts headers beyond the window size.
+func (w *chainWindow) add(header *types.Header) {
+    number := header.Number.Uint64()
+    if len(w.headers) == 0 || number != w.high+1 {
+        w.reset()
+        w.low = number
+    }
+    w.headers[number] = header
+    w.high = number
+    for w.high-w.low+1 > uint64(w.size) {
+        delete(w.headers, w.low)
+        w.low++
+    }
+}
+
+func (w *chainWindow) reset() {
+    w.headers = make(map[uint64]*types.Header)
+    w.low, w.high = 0, 0
+}
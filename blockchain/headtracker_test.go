@@ -0,0 +1,289 @@
+package blockchain
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math/big"
+    "reflect"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/event"
+)
+
+// fakeHeadSource serves headers by hash and hands every established subscription to the test.
+type fakeHeadSource struct {
+    mu       sync.Mutex
+    headers  map[common.Hash]*types.Header
+    failures int // subscription attempts left to refuse
+    subs     chan *fakeHeadSub
+}
+
+type fakeHeadSub struct {
+    headers chan<- *types.Header
+    drop    chan error
+}
+
+func newFakeHeadSource(chains ...[]*types.Header) *fakeHeadSource {
+    s := &fakeHeadSource{
+        headers: make(map[common.Hash]*types.Header),
+        subs:    make(chan *fakeHeadSub, 1),
+    }
+    for _, chain := range chains {
+        for _, header := range chain {
+            s.headers[header.Hash()] = header
+        }
+    }
+    return s
+}
+
+func (s *fakeHeadSource) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    header, ok := s.headers[hash]
+    if !ok {
+        return nil, ethereum.NotFound
+    }
+    return header, nil
+}
+
+func (s *fakeHeadSource) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+    s.mu.Lock()
+    if s.failures > 0 {
+        s.failures--
+        s.mu.Unlock()
+        return nil, errors.New("connection refused")
+    }
+    s.mu.Unlock()
+
+    sub := &fakeHeadSub{headers: ch, drop: make(chan error, 1)}
+    s.subs <- sub
+    return event.NewSubscription(func(quit <-chan struct{}) error {
+        select {
+        case err := <-sub.drop:
+            return err
+        case <-quit:
+            return nil
+        }
+    }), nil
+}
+
+/**
+  *  testChain builds count headers on top of parent, or a chain starting at
+  *  genesis when parent is nil. Chains built with a different fork byte have
+  *  different hashes at the same heights.
+  */
+func testChain(parent *types.Header, count int, fork byte) []*types.Header {
+    chain := make([]*types.Header, 0, count)
+    for i := 0; i < count; i++ {
+        header := &types.Header{Difficulty: big.NewInt(1), Number: big.NewInt(0), Extra: []byte{fork}}
+        if parent != nil {
+            header.ParentHash = parent.Hash()
+            header.Number = new(big.Int).Add(parent.Number, big.NewInt(1))
+        }
+        chain = append(chain, header)
+        parent = header
+    }
+    return chain
+}
+
+func newTestHeadTracker(source headSource) *HeadTracker {
+    t := newHeadTracker(source)
+    t.events = make(chan HeadEvent, 4*maxBackfillBlocks)
+    return t
+}
+
+// describeEvents drains the buffered events as short strings; forked headers are primed, as in "head 8'".
+func describeEvents(t *HeadTracker) []string {
+    var events []string
+    for {
+        select {
+        case ev := <-t.events:
+            events = append(events, describeEvent(ev))
+        default:
+            return events
+        }
+    }
+}
+
+func describeEvent(ev HeadEvent) string {
+    switch {
+    case ev.Gap != nil:
+        return fmt.Sprintf("gap %d-%d", ev.Gap.From, ev.Gap.To)
+    case ev.Reorg != nil:
+        r := ev.Reorg
+        ancestor := "none"
+        if r.CommonAncestor != nil {
+            ancestor = fmt.Sprint(r.CommonAncestor.Number)
+        }
+        return fmt.Sprintf("reorg depth %d ancestor %s removed %d-%d added %d-%d", r.Depth, ancestor,
+            r.Removed[0].Number, r.Removed[len(r.Removed)-1].Number, r.Added[0].Number, r.Added[len(r.Added)-1].Number)
+    }
+    mark := ""
+    if ev.Header.Extra[0] != 0 {
+        mark = "'"
+    }
+    return fmt.Sprintf("head %d%s", ev.Header.Number, mark)
+}
+
+func heads(from, to uint64, mark string) []string {
+    var events []string
+    for n := from; n <= to; n++ {
+        events = append(events, fmt.Sprintf("head %d%s", n, mark))
+    }
+    return events
+}
+
+func deliverAll(t *testing.T, tracker *HeadTracker, headers ...*types.Header) {
+    t.Helper()
+    for _, header := range headers {
+        if err := tracker.deliver(context.Background(), header); err != nil {
+            t.Fatalf("deliver block %d: %v", header.Number, err)
+        }
+    }
+}
+
+func TestHeadTrackerBackfill(t *testing.T) {
+    chain := testChain(nil, 20, 0)
+    tracker := newTestHeadTracker(newFakeHeadSource(chain))
+
+    deliverAll(t, tracker, chain[10], chain[14], chain[14], chain[12])
+    if got, want := describeEvents(tracker), heads(10, 14, ""); !reflect.DeepEqual(got, want) {
+        t.Fatalf("events %q, want %q", got, want)
+    }
+}
+
+func TestHeadTrackerWalkBack(t *testing.T) {
+    chain := testChain(nil, 11, 0)
+    fork := testChain(chain[7], 4, 1)
+    tracker := newTestHeadTracker(newFakeHeadSource(chain, fork))
+
+    deliverAll(t, tracker, chain[0], chain[10])
+    describeEvents(tracker)
+    deliverAll(t, tracker, fork[3])
+    want := append([]string{"reorg depth 3 ancestor 7 removed 8-10 added 8-11"}, heads(8, 11, "'")...)
+    if got := describeEvents(tracker); !reflect.DeepEqual(got, want) {
+        t.Fatalf("events %q, want %q", got, want)
+    }
+}
+
+func TestHeadTrackerGap(t *testing.T) {
+    chain := testChain(nil, 200, 0)
+    tracker := newTestHeadTracker(newFakeHeadSource(chain))
+
+    // Blocks 11 to 142 are missing when block 143 arrives; only the 127 before it are backfilled.
+    deliverAll(t, tracker, chain[10], chain[143])
+    want := append([]string{"head 10", "gap 11-15"}, heads(16, 143, "")...)
+    if got := describeEvents(tracker); !reflect.DeepEqual(got, want) {
+        t.Fatalf("events %q, want %q", got, want)
+    }
+
+    // The window restarts after the gap, so the next head extends it.
+    deliverAll(t, tracker, chain[144])
+    if got, want := describeEvents(tracker), heads(144, 144, ""); !reflect.DeepEqual(got, want) {
+        t.Fatalf("events %q, want %q", got, want)
+    }
+}
+
+func TestHeadTrackerBackoff(t *testing.T) {
+    chain := testChain(nil, 2, 0)
+    source := newFakeHeadSource(chain)
+    source.failures = 6
+    tracker := newHeadTracker(source)
+    var waits []time.Duration
+    tracker.after = func(d time.Duration) <-chan time.Time {
+        waits = append(waits, d)
+        ch := make(chan time.Time, 1)
+        ch <- time.Time{}
+        return ch
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    go tracker.Run(ctx)
+
+    sub := <-source.subs
+    sub.headers <- chain[1]
+    if ev := <-tracker.Events(); describeEvent(ev) != "head 1" {
+        t.Fatalf("got %s, want head 1", describeEvent(ev))
+    }
+    sub.drop <- errors.New("connection lost")
+    <-source.subs
+    cancel()
+    for range tracker.Events() {
+    }
+
+    // Failures double the wait up to the cap; a subscription that delivered resets it.
+    want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, time.Second}
+    if !reflect.DeepEqual(waits, want) {
+        t.Fatalf("waits %v, want %v", waits, want)
+    }
+}
+
+func TestHeadTrackerCommonAncestor(t *testing.T) {
+    tests := []struct {
+        name      string
+        canonical int // blocks 0 to canonical-1 are delivered before the fork
+        forkFrom  int // the fork builds on this canonical block
+        forkLen   int // and its last block is delivered as the new head
+        want      []string
+    }{
+        {
+            name:      "depth 1",
+            canonical: 11,
+            forkFrom:  9,
+            forkLen:   1,
+            want:      append([]string{"reorg depth 1 ancestor 9 removed 10-10 added 10-10"}, heads(10, 10, "'")...),
+        },
+        {
+            name:      "depth N",
+            canonical: 11,
+            forkFrom:  4,
+            forkLen:   7,
+            want:      append([]string{"reorg depth 6 ancestor 4 removed 5-10 added 5-11"}, heads(5, 11, "'")...),
+        },
+        {
+            // The window holds blocks 37 to 100; the walk stops at its bottom without an ancestor.
+            name:      "deeper than window",
+            canonical: 101,
+            forkFrom:  20,
+            forkLen:   81,
+            want:      append([]string{"reorg depth 64 ancestor none removed 37-100 added 37-101"}, heads(37, 101, "'")...),
+        },
+        {
+            // Blocks 11 to 14 were never seen; the fork is walked back across them to block 7.
+            name:      "gap and reorg",
+            canonical: 11,
+            forkFrom:  7,
+            forkLen:   8,
+            want:      append([]string{"reorg depth 3 ancestor 7 removed 8-10 added 8-15"}, heads(8, 15, "'")...),
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            chain := testChain(nil, tt.canonical, 0)
+            fork := testChain(chain[tt.forkFrom], tt.forkLen, 1)
+            tracker := newTestHeadTracker(newFakeHeadSource(chain, fork))
+
+            deliverAll(t, tracker, chain[0], chain[tt.canonical-1])
+            describeEvents(tracker)
+            deliverAll(t, tracker, fork[tt.forkLen-1])
+            if got := describeEvents(tracker); !reflect.DeepEqual(got, tt.want) {
+                t.Fatalf("events %q, want %q", got, tt.want)
+            }
+
+            // The replacement chain is canonical now, so a head building on it is not a reorg.
+            next := testChain(fork[tt.forkLen-1], 1, 1)[0]
+            tracker.fetcher.(*fakeHeadSource).headers[next.Hash()] = next
+            deliverAll(t, tracker, next)
+            if got, want := describeEvents(tracker), []string{describeEvent(HeadEvent{Header: next})}; !reflect.DeepEqual(got, want) {
+                t.Fatalf("events after reorg %q, want %q", got, want)
+            }
+        })
+    }
+}
@@ -0,0 +1,130 @@
+package blockchain
+
+import (
+    "context"
+    "encoding/base64"
+    "errors"
+    "fmt"
+)
+
+const (
+    defaultBlockPageSize = 20
+    maxBlockPageSize     = 100
+)
+
+var ErrInvalidPageRequest = errors.New("invalid page request")
+
+/**
+  *  BlockPageRequest selects one page of a block range. The range runs from
+  *  From (latest by default) towards To (earliest by default), descending when
+  *  From is above To and ascending otherwise. Cursor continues a previous page
+  *  and cannot be combined with From or To.
+  */
+type BlockPageRequest struct {
+    From     *BlockNumberArg `json:"from"`
+    To       *BlockNumberArg `json:"to"`
+    Cursor   string          `json:"cursor"`
+    PageSize *int            `json:"pageSize"`
+}
+
+// BlockPage is one page of blocks. Next is empty once the page reaches the end of the range.
+type BlockPage struct {
+    Blocks []Block `json:"blocks"`
+    From   uint64  `json:"from"`
+    To     uint64  `json:"to"`
+    Next   string  `json:"next,omitempty"`
+}
+
+/**
+  *  GetBlockPage returns the page of blocks selected by req. Bounds beyond the
+  *  current head, malformed cursors and out of range page sizes are rejected
+  *  with ErrInvalidPageRequest rather than adjusted.
+  */
+func (bf *BlockFetcher) GetBlockPage(ctx context.Context, req BlockPageRequest) (*BlockPage, error) {
+    size := defaultBlockPageSize
+    if req.PageSize != nil {
+        size = *req.PageSize
+        if size < 1 || size > maxBlockPageSize {
+            return nil, fmt.Errorf("%w: pageSize must be between 1 and %d, got %d", ErrInvalidPageRequest, maxBlockPageSize, size)
+        }
+    }
+
+    head, err := bf.resolveBlockNumber(ctx, LatestBlock)
+    if err != nil {
+        return nil, err
+    }
+
+    var start, end uint64
+    if req.Cursor != "" {
+        if req.From != nil || req.To != nil {
+            return nil, fmt.Errorf("%w: cursor cannot be combined with from or to", ErrInvalidPageRequest)
+        }
+        start, end, err = decodeBlockCursor(req.Cursor)
+        if err != nil {
+            return nil, err
+        }
+    } else {
+        fromArg, toArg := LatestBlock, EarliestBlock
+        if req.From != nil {
+            fromArg = *req.From
+        }
+        if req.To != nil {
+            toArg = *req.To
+        }
+        if start, err = bf.resolveBlockNumber(ctx, fromArg); err != nil {
+            return nil, err
+        }
+        if end, err = bf.resolveBlockNumber(ctx, toArg); err != nil {
+            return nil, err
+        }
+    }
+    if start > head || end > head {
+        return nil, fmt.Errorf("%w: block %d is after the latest block %d", ErrInvalidPageRequest, max(start, end), head)
+    }
+
+    numbers := make([]uint64, 0, size)
+    for n := start; len(numbers) < size; {
+        numbers = append(numbers, n)
+        if n == end {
+            break
+        }
+        if start > end {
+            n--
+        } else {
+            n++
+        }
+    }
+
+    blocks, err := bf.getBlocks(ctx, numbers)
+    if err != nil {
+        return nil, err
+    }
+    last := numbers[len(numbers)-1]
+    page := &BlockPage{Blocks: blocks, From: start, To: last}
+    if last != end {
+        next := last + 1
+        if start > end {
+            next = last - 1
+        }
+        page.Next = encodeBlockCursor(next, end)
+    }
+    return page, nil
+}
+
+// encodeBlockCursor packs the next block and the range end into an opaque token.
+func encodeBlockCursor(next, end uint64) string {
+    return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", next, end)))
+}
+
+// decodeBlockCursor accepts only tokens produced by encodeBlockCursor; Sscanf alone would ignore trailing input.
+func decodeBlockCursor(cursor string) (uint64, uint64, error) {
+    raw, err := base64.RawURLEncoding.DecodeString(cursor)
+    if err != nil {
+        return 0, 0, fmt.Errorf("%w: malformed cursor", ErrInvalidPageRequest)
+    }
+    var next, end uint64
+    if _, err := fmt.Sscanf(string(raw), "%d:%d", &next, &end); err != nil || encodeBlockCursor(next, end) != cursor {
+        return 0, 0, fmt.Errorf("%w: malformed cursor", ErrInvalidPageRequest)
+    }
+    return next, end, nil
+}
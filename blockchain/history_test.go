@@ -0,0 +1,53 @@
+package blockchain
+
+import (
+    "encoding/base64"
+    "errors"
+    "math"
+    "testing"
+)
+
+func TestBlockCursorRoundTrip(t *testing.T) {
+    for _, tt := range []struct{ next, end uint64 }{
+        {0, 0},
+        {41, 0},
+        {7, 1200},
+        {math.MaxUint64, 1},
+    } {
+        cursor := encodeBlockCursor(tt.next, tt.end)
+        next, end, err := decodeBlockCursor(cursor)
+        if err != nil {
+            t.Fatalf("decodeBlockCursor(%q): %v", cursor, err)
+        }
+        if next != tt.next || end != tt.end {
+            t.Fatalf("cursor %q decoded to %d:%d, want %d:%d", cursor, next, end, tt.next, tt.end)
+        }
+    }
+}
+
+func TestBlockCursorValidation(t *testing.T) {
+    encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+    tests := []struct {
+        name   string
+        cursor string
+    }{
+        {"not base64", "5:10"},
+        {"padded", base64.URLEncoding.EncodeToString([]byte("5:10"))},
+        {"standard alphabet", base64.RawStdEncoding.EncodeToString([]byte("\xfb\xff:1"))},
+        {"missing end", encode("5")},
+        {"missing separator", encode("5 10")},
+        {"negative", encode("-5:10")},
+        {"explicit sign", encode("+5:10")},
+        {"leading zero", encode("05:10")},
+        {"trailing input", encode("5:10:15")},
+        {"overflow", encode("18446744073709551616:0")},
+        {"empty", encode("")},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if next, end, err := decodeBlockCursor(tt.cursor); !errors.Is(err, ErrInvalidPageRequest) {
+                t.Fatalf("decodeBlockCursor(%q) = %d, %d, %v; want ErrInvalidPageRequest", tt.cursor, next, end, err)
+            }
+        })
+    }
+}
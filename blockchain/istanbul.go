@@ -0,0 +1,67 @@
+package blockchain
+
+import (
+    "context"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+)
+
+/**
+  *  istanbulAdapter serves the BFT engines of GoQuorum. IBFT lives in the
+  *  istanbul namespace and QBFT in the qbft namespace; both report the block
+  *  proposer as the author of getSignersFromBlockByHash.
+  */
+type istanbulAdapter struct {
+    pool             *NodePool
+    name             string
+    signersMethod    string
+    validatorsMethod string
+}
+
+func newIBFTAdapter(pool *NodePool) *istanbulAdapter {
+    return &istanbulAdapter{
+        pool:             pool,
+        name:             ConsensusIBFT,
+        signersMethod:    "istanbul_getSignersFromBlockByHash",
+        validatorsMethod: "istanbul_getValidators",
+    }
+}
+
+func newQBFTAdapter(pool *NodePool) *istanbulAdapter {
+    return &istanbulAdapter{
+        pool:             pool,
+        name:             ConsensusQBFT,
+        signersMethod:    "qbft_getSignersFromBlockByHash",
+        validatorsMethod: "qbft_getValidatorsByBlockNumber",
+    }
+}
+
+func (a *istanbulAdapter) Name() string {
+    return a.name
+}
+
+func (a *istanbulAdapter) Producer(ctx context.Context, header *types.Header) (*BlockProducer, error) {
+    var signers struct {
+        Author common.Address `json:"author"`
+    }
+    err := a.pool.Do(ctx, func(n *Node) error {
+        return n.RPCClient.CallContext(ctx, &signers, a.signersMethod, header.Hash())
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &BlockProducer{Address: signers.Author}, nil
+}
+
+func (a *istanbulAdapter) Validators(ctx context.Context, number *big.Int) ([]common.Address, error) {
+    var validators []common.Address
+    err := a.pool.Do(ctx, func(n *Node) error {
+        return n.RPCClient.CallContext(ctx, &validators, a.validatorsMethod, numberArg(number))
+    })
+    if err != nil {
+        return nil, err
+    }
+    return validators, nil
+}
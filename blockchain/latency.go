@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+    "sort"
+    "time"
+)
+
+// latencyWindowSize is the number of health probes kept per node, five minutes at the default interval.
+const latencyWindowSize = 30
+
+/**
+  *  LatencyStats summarizes the health probes of a node over the rolling
+  *  window. Latency figures only cover successful probes; ErrorRate is the
+  *  share of failed probes, between 0 and 1.
+  */
+type LatencyStats struct {
+    Samples   int     `json:"samples"`
+    MinMs     float64 `json:"minMs"`
+    AvgMs     float64 `json:"avgMs"`
+    P95Ms     float64 `json:"p95Ms"`
+    ErrorRate float64 `json:"errorRate"`
+}
+
+type probeSample struct {
+    latency time.Duration
+    ok      bool
+}
+
+// latencyWindow is a ring buffer of the most recent probes of a node.
+type latencyWindow struct {
+    samples []probeSample
+    next    int
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+    return &latencyWindow{samples: make([]probeSample, 0, size)}
+}
+
+func (w *latencyWindow) add(latency time.Duration, ok bool) {
+    sample := probeSample{latency: latency, ok: ok}
+    if len(w.samples) < cap(w.samples) {
+        w.samples = append(w.samples, sample)
+        return
+    }
+    w.samples[w.next] = sample
+    w.next = (w.next + 1) % len(w.samples)
+}
+
+func (w *latencyWindow) stats() LatencyStats {
+    stats := LatencyStats{Samples: len(w.samples)}
+    if len(w.samples) == 0 {
+        return stats
+    }
+    var latencies []time.Duration
+    var total time.Duration
+    for _, s := range w.samples {
+        if !s.ok {
+            continue
+        }
+        latencies = append(latencies, s.latency)
+        total += s.latency
+    }
+    stats.ErrorRate = float64(len(w.samples)-len(latencies)) / float64(len(w.samples))
+    if len(latencies) == 0 {
+        return stats
+    }
+
+    sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+    // Nearest-rank percentile.
+    rank := (len(latencies)*95 + 99) / 100
+    stats.MinMs = durationMs(latencies[0])
+    stats.AvgMs = durationMs(total / time.Duration(len(latencies)))
+    stats.P95Ms = durationMs(latencies[rank-1])
+    return stats
+}
+
+func durationMs(d time.Duration) float64 {
+    return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,163 @@
+package blockchain
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+    defaultMaxLogBlockRange = 2000
+    maxLogTopics            = 4
+)
+
+var (
+    ErrBlockRangeTooLarge = errors.New("block range too large")
+    ErrInvalidBlockRange  = errors.New("invalid block range")
+)
+
+/**
+  *  LogFilter mirrors the eth_getLogs filter object. Address may be a single
+  *  address or a list; each topic position may be null (wildcard), a single
+  *  topic or a list of alternatives.
+  */
+type LogFilter struct {
+    BlockHash *common.Hash
+    FromBlock *BlockNumberArg
+    ToBlock   *BlockNumberArg
+    Addresses []common.Address
+    Topics    [][]common.Hash
+}
+
+func (f *LogFilter) UnmarshalJSON(data []byte) error {
+    var raw struct {
+        BlockHash *common.Hash      `json:"blockHash"`
+        FromBlock *BlockNumberArg   `json:"fromBlock"`
+        ToBlock   *BlockNumberArg   `json:"toBlock"`
+        Address   json.RawMessage   `json:"address"`
+        Topics    []json.RawMessage `json:"topics"`
+    }
+    if err := json.Unmarshal(data, &raw); err != nil {
+        return err
+    }
+    if raw.BlockHash != nil && (raw.FromBlock != nil || raw.ToBlock != nil) {
+        return fmt.Errorf("cannot specify both blockHash and fromBlock/toBlock")
+    }
+    if len(raw.Topics) > maxLogTopics {
+        return fmt.Errorf("at most %d topic positions are allowed", maxLogTopics)
+    }
+
+    f.BlockHash = raw.BlockHash
+    f.FromBlock = raw.FromBlock
+    f.ToBlock = raw.ToBlock
+    f.Addresses = nil
+    f.Topics = nil
+
+    if len(raw.Address) > 0 && string(raw.Address) != "null" {
+        if raw.Address[0] == '[' {
+            if err := json.Unmarshal(raw.Address, &f.Addresses); err != nil {
+                return fmt.Errorf("invalid address filter: %v", err)
+            }
+        } else {
+            var single common.Address
+            if err := json.Unmarshal(raw.Address, &single); err != nil {
+                return fmt.Errorf("invalid address filter: %v", err)
+            }
+            f.Addresses = []common.Address{single}
+        }
+    }
+
+    for i, rawTopic := range raw.Topics {
+        var position []common.Hash
+        if len(rawTopic) > 0 && string(rawTopic) != "null" {
+            if rawTopic[0] == '[' {
+                if err := json.Unmarshal(rawTopic, &position); err != nil {
+                    return fmt.Errorf("invalid topic filter at position %d: %v", i, err)
+                }
+            } else {
+                var single common.Hash
+                if err := json.Unmarshal(rawTopic, &single); err != nil {
+                    return fmt.Errorf("invalid topic filter at position %d: %v", i, err)
+                }
+                position = []common.Hash{single}
+            }
+        }
+        f.Topics = append(f.Topics, position)
+    }
+    return nil
+}
+
+// Query converts the address and topic criteria of f to an ethereum.FilterQuery.
+func (f LogFilter) Query() ethereum.FilterQuery {
+    return ethereum.FilterQuery{
+        BlockHash: f.BlockHash,
+        Addresses: f.Addresses,
+        Topics:    f.Topics,
+    }
+}
+
+/**
+  *  GetLogs returns the logs matching f. Missing bounds default to "latest"
+  *  and tags are resolved to concrete numbers first so the span can be checked
+  *  against the configured limit.
+  */
+func (bf *BlockFetcher) GetLogs(ctx context.Context, f LogFilter) ([]types.Log, error) {
+    q := f.Query()
+    if f.BlockHash == nil {
+        fromArg, toArg := LatestBlock, LatestBlock
+        if f.FromBlock != nil {
+            fromArg = *f.FromBlock
+        }
+        if f.ToBlock != nil {
+            toArg = *f.ToBlock
+        }
+        from, err := bf.resolveBlockNumber(ctx, fromArg)
+        if err != nil {
+            return nil, err
+        }
+        to, err := bf.resolveBlockNumber(ctx, toArg)
+        if err != nil {
+            return nil, err
+        }
+        if from > to {
+            return nil, fmt.Errorf("%w: fromBlock %d is after toBlock %d", ErrInvalidBlockRange, from, to)
+        }
+        if to-from+1 > bf.config.MaxLogBlockRange {
+            return nil, fmt.Errorf("%w: %d blocks requested, at most %d allowed", ErrBlockRangeTooLarge, to-from+1, bf.config.MaxLogBlockRange)
+        }
+        q.FromBlock = new(big.Int).SetUint64(from)
+        q.ToBlock = new(big.Int).SetUint64(to)
+    }
+
+    var logs []types.Log
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        logs, err = n.Client.FilterLogs(ctx, q)
+        return err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch logs: %v", err)
+    }
+    return logs, nil
+}
+
+// resolveBlockNumber turns a block tag into the number of the block it currently points to.
+func (bf *BlockFetcher) resolveBlockNumber(ctx context.Context, b BlockNumberArg) (uint64, error) {
+    if !b.IsTag() {
+        return uint64(b), nil
+    }
+    if b == EarliestBlock {
+        return 0, nil
+    }
+    header, err := bf.HeaderByNumber(ctx, b.BigInt())
+    if err != nil {
+        return 0, fmt.Errorf("failed to resolve block %s: %v", b, err)
+    }
+    return header.Number.Uint64(), nil
+}
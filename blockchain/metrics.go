@@ -0,0 +1,168 @@
+package blockchain
+
+import (
+    "context"
+    "fmt"
+    "runtime"
+    "sync"
+    "time"
+
+    "github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+    defaultMetricsInterval = 15 * time.Second
+    metricsFieldTimeout    = 5 * time.Second
+)
+
+/**
+  *  NetworkMetrics is one snapshot of the collector. A field whose refresh
+  *  failed keeps its previous value and the failure is reported in Errors,
+  *  keyed by the JSON name of the field.
+  */
+type NetworkMetrics struct {
+    UpdatedAt        time.Time         `json:"updatedAt"`
+    AverageBlockTime float64           `json:"averageBlockTime"`
+    BlockTime        BlockTimeSummary  `json:"blockTime"`
+    Difficulty       string            `json:"difficulty"`
+    Hashrate         string            `json:"hashrate"`
+    Latency          float64           `json:"latency"`
+    Nodes            []NodeStatus      `json:"nodes"`
+    BlockCache       BlockCacheStats   `json:"blockCache"`
+    MemoryUsage      string            `json:"memoryUsage"`
+    Errors           map[string]string `json:"errors,omitempty"`
+}
+
+/**
+  *  MetricsCollector refreshes the network metrics on its own schedule and
+  *  serves the last snapshot to every caller, so block delivery never waits
+  *  on metrics RPCs. Each field is fetched with its own timeout and a slow or
+  *  failing call only degrades that field.
+  */
+type MetricsCollector struct {
+    fetcher  *BlockFetcher
+    interval time.Duration
+    updates  chan NetworkMetrics
+    ready    chan struct{}
+
+    mu       sync.RWMutex
+    snapshot NetworkMetrics
+}
+
+func NewMetricsCollector(fetcher *BlockFetcher) *MetricsCollector {
+    return &MetricsCollector{
+        fetcher:  fetcher,
+        interval: fetcher.config.MetricsInterval,
+        updates:  make(chan NetworkMetrics, 1),
+        ready:    make(chan struct{}),
+    }
+}
+
+// Updates delivers each new snapshot. A slow reader only sees the latest one. It is closed when Run returns.
+func (c *MetricsCollector) Updates() <-chan NetworkMetrics {
+    return c.updates
+}
+
+// Run refreshes the snapshot immediately and then every interval until ctx is cancelled.
+func (c *MetricsCollector) Run(ctx context.Context) {
+    defer close(c.updates)
+
+    ticker := time.NewTicker(c.interval)
+    defer ticker.Stop()
+    for {
+        c.refresh(ctx)
+        select {
+        case <-ticker.C:
+        case <-ctx.Done():
+            return
+        }
+    }
+}
+
+/**
+  *  Snapshot returns the last collected metrics. Before the first refresh
+  *  completes it waits for it, or for ctx to expire.
+  */
+func (c *MetricsCollector) Snapshot(ctx context.Context) (NetworkMetrics, error) {
+    select {
+    case <-c.ready:
+    case <-ctx.Done():
+        return NetworkMetrics{}, ctx.Err()
+    }
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.snapshot, nil
+}
+
+func (c *MetricsCollector) refresh(ctx context.Context) {
+    c.mu.RLock()
+    metrics := c.snapshot
+    c.mu.RUnlock()
+    metrics.Errors = make(map[string]string)
+
+    fieldCtx, cancel := context.WithTimeout(ctx, metricsFieldTimeout)
+    blockTimes, err := c.fetcher.BlockTimes(fieldCtx)
+    cancel()
+    if err != nil {
+        metrics.Errors["blockTime"] = fmt.Sprintf("failed to calculate block time statistics: %v", err)
+    } else {
+        metrics.AverageBlockTime = blockTimes.MeanSeconds
+        metrics.BlockTime = blockTimes
+    }
+
+    fieldCtx, cancel = context.WithTimeout(ctx, metricsFieldTimeout)
+    var header *types.Header
+    err = c.fetcher.pool.Do(fieldCtx, func(n *Node) error {
+        var err error
+        header, err = n.Client.HeaderByNumber(fieldCtx, nil)
+        return err
+    })
+    cancel()
+    if err != nil {
+        metrics.Errors["difficulty"] = fmt.Sprintf("failed to fetch latest header: %v", err)
+    } else {
+        metrics.Difficulty = header.Difficulty.String()
+    }
+
+    fieldCtx, cancel = context.WithTimeout(ctx, metricsFieldTimeout)
+    var hashrate string
+    err = c.fetcher.pool.Do(fieldCtx, func(n *Node) error {
+        return n.RPCClient.CallContext(fieldCtx, &hashrate, "eth_hashrate")
+    })
+    cancel()
+    if err != nil {
+        metrics.Errors["hashrate"] = fmt.Sprintf("failed to fetch network hashrate: %v", err)
+    } else {
+        metrics.Hashrate = hashrate
+    }
+
+    metrics.Latency = c.fetcher.networkLatency()
+    metrics.Nodes = c.fetcher.pool.Status()
+    metrics.BlockCache = c.fetcher.BlockCacheStats()
+
+    var memStats runtime.MemStats
+    runtime.ReadMemStats(&memStats)
+    metrics.MemoryUsage = fmt.Sprintf("%.2f MB", float64(memStats.Alloc)/1024/1024)
+
+    if len(metrics.Errors) == 0 {
+        metrics.Errors = nil
+    }
+    metrics.UpdatedAt = time.Now()
+
+    if ctx.Err() != nil {
+        return
+    }
+    c.mu.Lock()
+    first := c.snapshot.UpdatedAt.IsZero()
+    c.snapshot = metrics
+    c.mu.Unlock()
+    if first {
+        close(c.ready)
+    }
+
+    select {
+    case <-c.updates:
+    default:
+    }
+    c.updates <- metrics
+}
@@ -0,0 +1,422 @@
+package blockchain
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/ethclient"
+    "github.com/ethereum/go-ethereum/event"
+    "github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+    StrategyRoundRobin    = "round-robin"
+    StrategyLowestLatency = "lowest-latency"
+)
+
+const (
+    healthCheckInterval = 10 * time.Second
+    healthCheckTimeout  = 5 * time.Second
+)
+
+var ErrNoHealthyNodes = errors.New("no healthy nodes available")
+
+/**
+  *  Node is a single configured Ethereum endpoint. Its clients are nil until
+  *  the first successful dial, which the health checker keeps retrying.
+  */
+type Node struct {
+    URL       string
+    Client    *ethclient.Client
+    RPCClient *rpc.Client
+
+    mu          sync.RWMutex
+    connected   bool
+    healthy     bool
+    latency     time.Duration
+    probes      *latencyWindow
+    blockNumber uint64
+    lastError   error
+    lastCheck   time.Time
+}
+
+type NodeStatus struct {
+    URL           string       `json:"url"`
+    Healthy       bool         `json:"healthy"`
+    Subscriptions bool         `json:"subscriptions"`
+    LatencyMs     float64      `json:"latencyMs"`
+    Latency       LatencyStats `json:"latency"`
+    BlockNumber   uint64       `json:"blockNumber"`
+    LastError     string       `json:"lastError,omitempty"`
+    LastCheck     time.Time    `json:"lastCheck"`
+}
+
+/**
+  *  NodePool health-checks every configured node and routes reads to the
+  *  healthy ones, failing over to the next node on transport errors.
+  */
+type NodePool struct {
+    nodes    []*Node
+    strategy string
+    next     uint64
+    quit     chan struct{}
+    once     sync.Once
+    wg       sync.WaitGroup
+}
+
+func NewNodePool(ctx context.Context, nodeURLs []string, strategy string) (*NodePool, error) {
+    if len(nodeURLs) == 0 {
+        return nil, fmt.Errorf("node pool requires at least one node")
+    }
+    switch strategy {
+    case "":
+        strategy = StrategyRoundRobin
+    case StrategyRoundRobin, StrategyLowestLatency:
+    default:
+        return nil, fmt.Errorf("unknown node selection strategy %q", strategy)
+    }
+
+    p := &NodePool{
+        strategy: strategy,
+        quit:     make(chan struct{}),
+    }
+    for _, url := range nodeURLs {
+        p.nodes = append(p.nodes, &Node{URL: url, probes: newLatencyWindow(latencyWindowSize)})
+    }
+
+    p.CheckHealth(ctx)
+    if len(p.connectedNodes()) == 0 {
+        p.closeNodes()
+        return nil, fmt.Errorf("failed to connect to any Ethereum node")
+    }
+
+    p.wg.Add(1)
+    go p.monitor()
+    return p, nil
+}
+
+func (p *NodePool) Nodes() []*Node {
+    return p.nodes
+}
+
+func (p *NodePool) Status() []NodeStatus {
+    statuses := make([]NodeStatus, len(p.nodes))
+    for i, n := range p.nodes {
+        statuses[i] = n.Status()
+    }
+    return statuses
+}
+
+// Close stops the health checker and closes every node connection.
+func (p *NodePool) Close() {
+    p.once.Do(func() { close(p.quit) })
+    p.wg.Wait()
+    p.closeNodes()
+}
+
+func (p *NodePool) closeNodes() {
+    for _, n := range p.nodes {
+        n.mu.Lock()
+        if n.connected {
+            n.RPCClient.Close()
+            n.connected = false
+            n.healthy = false
+        }
+        n.mu.Unlock()
+    }
+}
+
+// CheckHealth probes every node concurrently with eth_blockNumber and records the probe latency.
+func (p *NodePool) CheckHealth(ctx context.Context) {
+    var wg sync.WaitGroup
+    for _, n := range p.nodes {
+        wg.Add(1)
+        go func(n *Node) {
+            defer wg.Done()
+            ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+            defer cancel()
+            n.check(ctx)
+        }(n)
+    }
+    wg.Wait()
+}
+
+func (p *NodePool) monitor() {
+    defer p.wg.Done()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go func() {
+        <-p.quit
+        cancel()
+    }()
+
+    ticker := time.NewTicker(healthCheckInterval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            p.CheckHealth(ctx)
+        case <-p.quit:
+            return
+        }
+    }
+}
+
+/**
+  *  Do runs fn against a node picked by the pool strategy. Transport failures
+  *  mark the node unhealthy and retry on the next candidate; JSON-RPC errors
+  *  and "not found" answers are returned as-is since another node would give
+  *  the same answer.
+  */
+func (p *NodePool) Do(ctx context.Context, fn func(n *Node) error) error {
+    tried := make(map[*Node]bool)
+    var lastErr error
+    for {
+        n := p.pick(tried, false)
+        if n == nil {
+            if lastErr != nil {
+                return lastErr
+            }
+            return ErrNoHealthyNodes
+        }
+        tried[n] = true
+
+        err := fn(n)
+        if err == nil || !isFailoverError(ctx, err) {
+            return err
+        }
+        log.Printf("Node %s failed, failing over: %v", n.URL, err)
+        n.markUnhealthy(err)
+        lastErr = err
+    }
+}
+
+/**
+  *  Subscribe establishes a subscription through fn on the first healthy node
+  *  that supports subscriptions, trying the next one if it cannot be
+  *  established. The node is marked unhealthy if its subscription later fails;
+  *  re-subscribing is left to the caller (see HeadTracker).
+  */
+func (p *NodePool) Subscribe(ctx context.Context, fn func(n *Node) (ethereum.Subscription, error)) (ethereum.Subscription, error) {
+    tried := make(map[*Node]bool)
+    for {
+        n := p.pick(tried, true)
+        if n == nil {
+            return nil, ErrNoHealthyNodes
+        }
+        tried[n] = true
+        sub, err := fn(n)
+        if err != nil {
+            if ctx.Err() != nil {
+                return nil, err
+            }
+            log.Printf("Failed to subscribe on %s: %v", n.URL, err)
+            n.markUnhealthy(err)
+            continue
+        }
+        log.Printf("Subscription established on %s", n.URL)
+        return event.NewSubscription(func(quit <-chan struct{}) error {
+            defer sub.Unsubscribe()
+            select {
+            case err := <-sub.Err():
+                if err != nil {
+                    n.markUnhealthy(err)
+                }
+                return err
+            case <-quit:
+                return nil
+            }
+        }), nil
+    }
+}
+
+/**
+  *  pick selects a candidate node, skipping the excluded ones. Healthy nodes are
+  *  preferred; if none is healthy any connected node is tried so that a single
+  *  failed health check does not take the gateway offline. Subscriptions always
+  *  go to the first eligible node in configuration order to keep them stable.
+  */
+func (p *NodePool) pick(exclude map[*Node]bool, subscriptions bool) *Node {
+    var healthy, connected []*Node
+    for _, n := range p.nodes {
+        if exclude[n] {
+            continue
+        }
+        n.mu.RLock()
+        ok, up, client := n.healthy, n.connected, n.RPCClient
+        n.mu.RUnlock()
+        if !up || (subscriptions && !client.SupportsSubscriptions()) {
+            continue
+        }
+        connected = append(connected, n)
+        if ok {
+            healthy = append(healthy, n)
+        }
+    }
+
+    candidates := healthy
+    if len(candidates) == 0 {
+        candidates = connected
+    }
+    if len(candidates) == 0 {
+        return nil
+    }
+    if subscriptions {
+        return candidates[0]
+    }
+
+    switch p.strategy {
+    case StrategyLowestLatency:
+        best := candidates[0]
+        for _, n := range candidates[1:] {
+            if n.Latency() < best.Latency() {
+                best = n
+            }
+        }
+        return best
+    default:
+        idx := atomic.AddUint64(&p.next, 1)
+        return candidates[idx%uint64(len(candidates))]
+    }
+}
+
+func (p *NodePool) connectedNodes() []*Node {
+    var nodes []*Node
+    for _, n := range p.nodes {
+        n.mu.RLock()
+        if n.connected {
+            nodes = append(nodes, n)
+        }
+        n.mu.RUnlock()
+    }
+    return nodes
+}
+
+/****************************************** Node methods ******************************************/
+/**************************************************************************************************/
+
+func (n *Node) Healthy() bool {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return n.healthy
+}
+
+func (n *Node) Latency() time.Duration {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return n.latency
+}
+
+// LatencyStats summarizes the node's recent health probes.
+func (n *Node) LatencyStats() LatencyStats {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return n.probes.stats()
+}
+
+func (n *Node) Status() NodeStatus {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    status := NodeStatus{
+        URL:         n.URL,
+        Healthy:     n.healthy,
+        LatencyMs:   durationMs(n.latency),
+        Latency:     n.probes.stats(),
+        BlockNumber: n.blockNumber,
+        LastCheck:   n.lastCheck,
+    }
+    if n.connected {
+        status.Subscriptions = n.RPCClient.SupportsSubscriptions()
+    }
+    if n.lastError != nil {
+        status.LastError = n.lastError.Error()
+    }
+    return status
+}
+
+/**
+  *  check dials the node if it is not connected yet and probes it. The dial
+  *  runs without holding n.mu, so routing is never stalled by a dead node;
+  *  the lock is only taken to install the new clients.
+  */
+func (n *Node) check(ctx context.Context) {
+    n.mu.RLock()
+    rpcClient, connected := n.RPCClient, n.connected
+    n.mu.RUnlock()
+    if !connected {
+        dialed, err := rpc.DialContext(ctx, n.URL)
+        if err != nil {
+            n.mu.Lock()
+            n.healthy = false
+            n.lastError = err
+            n.lastCheck = time.Now()
+            n.probes.add(0, false)
+            n.mu.Unlock()
+            log.Printf("Failed to connect to node %s: %v", n.URL, err)
+            return
+        }
+        n.mu.Lock()
+        if n.connected {
+            // Another check connected the node while this one was dialing.
+            rpcClient = n.RPCClient
+            n.mu.Unlock()
+            dialed.Close()
+        } else {
+            n.RPCClient = dialed
+            n.Client = ethclient.NewClient(dialed)
+            n.connected = true
+            rpcClient = dialed
+            n.mu.Unlock()
+        }
+    }
+
+    var number hexutil.Uint64
+    start := time.Now()
+    err := rpcClient.CallContext(ctx, &number, "eth_blockNumber")
+    elapsed := time.Since(start)
+
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.lastCheck = time.Now()
+    n.probes.add(elapsed, err == nil)
+    if err != nil {
+        if n.healthy {
+            log.Printf("Node %s became unhealthy: %v", n.URL, err)
+        }
+        n.healthy = false
+        n.lastError = err
+        return
+    }
+    if !n.healthy {
+        log.Printf("Node %s is healthy", n.URL)
+    }
+    n.healthy = true
+    n.latency = elapsed
+    n.blockNumber = uint64(number)
+    n.lastError = nil
+}
+
+func (n *Node) markUnhealthy(err error) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.healthy = false
+    n.lastError = err
+}
+
+func isFailoverError(ctx context.Context, err error) bool {
+    if ctx.Err() != nil {
+        return false
+    }
+    if errors.Is(err, ethereum.NotFound) {
+        return false
+    }
+    var rpcErr rpc.Error
+    return !errors.As(err, &rpcErr)
+}
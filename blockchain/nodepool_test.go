@@ -0,0 +1,294 @@
+package blockchain
+
+import (
+    "context"
+    "errors"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/rpc"
+)
+
+// testEthAPI is the eth namespace of a fake node.
+type testEthAPI struct {
+    subscribeErr error
+}
+
+func (api *testEthAPI) BlockNumber() hexutil.Uint64 {
+    return 100
+}
+
+func (api *testEthAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+    if api.subscribeErr != nil {
+        return nil, api.subscribeErr
+    }
+    notifier, ok := rpc.NotifierFromContext(ctx)
+    if !ok {
+        return nil, rpc.ErrNotificationsUnsupported
+    }
+    return notifier.CreateSubscription(), nil
+}
+
+// testNode is a fake node served over HTTP, or over WebSocket when it must support subscriptions.
+type testNode struct {
+    url  string
+    once sync.Once
+    stop func()
+}
+
+func newTestNode(t *testing.T, api *testEthAPI, websocket bool) *testNode {
+    t.Helper()
+    srv := rpc.NewServer()
+    if err := srv.RegisterName("eth", api); err != nil {
+        t.Fatalf("failed to register fake eth API: %v", err)
+    }
+    var handler http.Handler = srv
+    if websocket {
+        handler = srv.WebsocketHandler([]string{"*"})
+    }
+    hs := httptest.NewServer(handler)
+    node := &testNode{url: hs.URL, stop: func() {
+        srv.Stop()
+        hs.CloseClientConnections()
+        hs.Close()
+    }}
+    if websocket {
+        node.url = "ws" + strings.TrimPrefix(hs.URL, "http")
+    }
+    t.Cleanup(node.Close)
+    return node
+}
+
+// Close takes the node offline; connections to it fail from then on.
+func (tn *testNode) Close() {
+    tn.once.Do(tn.stop)
+}
+
+func newTestPool(t *testing.T, strategy string, nodes ...*testNode) *NodePool {
+    t.Helper()
+    urls := make([]string, len(nodes))
+    for i, node := range nodes {
+        urls[i] = node.url
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    pool, err := NewNodePool(ctx, urls, strategy)
+    if err != nil {
+        t.Fatalf("NewNodePool: %v", err)
+    }
+    t.Cleanup(pool.Close)
+    return pool
+}
+
+// blockNumberVia runs eth_blockNumber through the pool and returns the URL of the node that answered.
+func blockNumberVia(t *testing.T, pool *NodePool) string {
+    t.Helper()
+    var answered string
+    err := pool.Do(context.Background(), func(n *Node) error {
+        var number hexutil.Uint64
+        if err := n.RPCClient.CallContext(context.Background(), &number, "eth_blockNumber"); err != nil {
+            return err
+        }
+        answered = n.URL
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("Do: %v", err)
+    }
+    return answered
+}
+
+func TestNodePoolFailover(t *testing.T) {
+    first := newTestNode(t, &testEthAPI{}, false)
+    second := newTestNode(t, &testEthAPI{}, false)
+    pool := newTestPool(t, StrategyRoundRobin, first, second)
+
+    first.Close()
+    for i := 0; i < 4; i++ {
+        if got := blockNumberVia(t, pool); got != second.url {
+            t.Fatalf("call %d answered by %s, want %s", i, got, second.url)
+        }
+    }
+    if pool.nodes[0].Healthy() {
+        t.Fatal("failed node is still healthy")
+    }
+
+    second.Close()
+    err := pool.Do(context.Background(), func(n *Node) error {
+        var number hexutil.Uint64
+        return n.RPCClient.CallContext(context.Background(), &number, "eth_blockNumber")
+    })
+    if err == nil {
+        t.Fatal("Do succeeded with every node down")
+    }
+}
+
+func TestNodePoolDoesNotFailOverOnRPCErrors(t *testing.T) {
+    first := newTestNode(t, &testEthAPI{}, false)
+    second := newTestNode(t, &testEthAPI{}, false)
+    pool := newTestPool(t, StrategyLowestLatency, first, second)
+
+    tried := 0
+    err := pool.Do(context.Background(), func(n *Node) error {
+        tried++
+        return n.RPCClient.CallContext(context.Background(), nil, "eth_unknownMethod")
+    })
+    var rpcErr rpc.Error
+    if !errors.As(err, &rpcErr) {
+        t.Fatalf("got error %v, want the node's JSON-RPC error", err)
+    }
+    if tried != 1 {
+        t.Fatalf("tried %d nodes, want 1", tried)
+    }
+    for _, n := range pool.nodes {
+        if !n.Healthy() {
+            t.Fatalf("node %s was marked unhealthy by a JSON-RPC error", n.URL)
+        }
+    }
+}
+
+func TestNodePoolRoundRobin(t *testing.T) {
+    nodes := []*testNode{
+        newTestNode(t, &testEthAPI{}, false),
+        newTestNode(t, &testEthAPI{}, false),
+        newTestNode(t, &testEthAPI{}, false),
+    }
+    pool := newTestPool(t, StrategyRoundRobin, nodes...)
+
+    counts := make(map[string]int)
+    for i := 0; i < 3*len(nodes); i++ {
+        counts[blockNumberVia(t, pool)]++
+    }
+    for _, node := range nodes {
+        if counts[node.url] != 3 {
+            t.Fatalf("calls per node %v, want 3 each", counts)
+        }
+    }
+}
+
+func TestNodePoolLowestLatency(t *testing.T) {
+    nodes := []*testNode{
+        newTestNode(t, &testEthAPI{}, false),
+        newTestNode(t, &testEthAPI{}, false),
+        newTestNode(t, &testEthAPI{}, false),
+    }
+    pool := newTestPool(t, StrategyLowestLatency, nodes...)
+    for i, latency := range []time.Duration{30 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond} {
+        n := pool.nodes[i]
+        n.mu.Lock()
+        n.latency = latency
+        n.mu.Unlock()
+    }
+
+    for i := 0; i < 3; i++ {
+        if got := blockNumberVia(t, pool); got != nodes[1].url {
+            t.Fatalf("call %d answered by %s, want the fastest node %s", i, got, nodes[1].url)
+        }
+    }
+    pool.nodes[1].markUnhealthy(errors.New("probe failed"))
+    if got := blockNumberVia(t, pool); got != nodes[2].url {
+        t.Fatalf("answered by %s, want the fastest healthy node %s", got, nodes[2].url)
+    }
+}
+
+func TestNodePoolSubscriptionFailover(t *testing.T) {
+    httpOnly := newTestNode(t, &testEthAPI{}, false)
+    refusing := newTestNode(t, &testEthAPI{subscribeErr: errors.New("subscriptions disabled")}, true)
+    serving := newTestNode(t, &testEthAPI{}, true)
+    pool := newTestPool(t, StrategyRoundRobin, httpOnly, refusing, serving)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    var subscribedTo []string
+    headers := make(chan *types.Header)
+    sub, err := pool.Subscribe(ctx, func(n *Node) (ethereum.Subscription, error) {
+        subscribedTo = append(subscribedTo, n.URL)
+        return n.Client.SubscribeNewHead(ctx, headers)
+    })
+    if err != nil {
+        t.Fatalf("Subscribe: %v", err)
+    }
+    defer sub.Unsubscribe()
+
+    // The HTTP node cannot carry subscriptions and is never tried.
+    if want := []string{refusing.url, serving.url}; strings.Join(subscribedTo, ",") != strings.Join(want, ",") {
+        t.Fatalf("subscribed to %v, want %v", subscribedTo, want)
+    }
+    if pool.nodes[1].Healthy() {
+        t.Fatal("node refusing the subscription is still healthy")
+    }
+
+    // Losing the node ends the subscription and marks the node unhealthy, so the caller re-subscribes elsewhere.
+    serving.Close()
+    select {
+    case err := <-sub.Err():
+        if err == nil {
+            t.Fatal("subscription ended without an error")
+        }
+    case <-ctx.Done():
+        t.Fatal("subscription did not fail after its node went down")
+    }
+    if pool.nodes[2].Healthy() {
+        t.Fatal("node that dropped the subscription is still healthy")
+    }
+}
+
+// A node that hangs while being dialed must not hold up routing to the other nodes.
+func TestNodePoolPickDuringDial(t *testing.T) {
+    live := newTestNode(t, &testEthAPI{}, false)
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer listener.Close()
+    accepted := make(chan net.Conn, 1)
+    go func() {
+        conn, err := listener.Accept()
+        if err == nil {
+            accepted <- conn
+        }
+    }()
+
+    pool := newTestPool(t, StrategyRoundRobin, live)
+    hanging := &Node{URL: "ws://" + listener.Addr().String(), probes: newLatencyWindow(latencyWindowSize)}
+    pool.nodes = append(pool.nodes, hanging)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        hanging.check(ctx)
+    }()
+    defer func() {
+        cancel()
+        <-done
+    }()
+
+    select {
+    case conn := <-accepted:
+        defer conn.Close()
+    case <-time.After(5 * time.Second):
+        t.Fatal("the hanging node was never dialed")
+    }
+    picked := make(chan *Node, 1)
+    go func() { picked <- pool.pick(nil, false) }()
+    select {
+    case n := <-picked:
+        if n != pool.nodes[0] {
+            t.Fatalf("picked %v, want the live node", n)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("pick blocked on a node being dialed")
+    }
+    if hanging.Status().Healthy {
+        t.Fatal("node being dialed reported healthy")
+    }
+}
@@ -0,0 +1,265 @@
+package blockchain
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+    TracerCall   = "callTracer"
+    TracerStruct = "structLogger"
+
+    // rpcMethodNotFound is the JSON-RPC code for methods a node does not expose.
+    rpcMethodNotFound = -32601
+)
+
+var ErrTracingNotSupported = errors.New("no configured node exposes debug_traceTransaction")
+
+/**
+  *  PendingTransaction is a mempool transaction. It carries the BlockTransaction
+  *  fields plus the gas parameters a transaction is priced by while it waits to
+  *  be mined. GasPrice is in wei as a decimal string; for dynamic fee
+  *  transactions it is the fee cap.
+  */
+type PendingTransaction struct {
+    BlockTransaction
+    GasPrice string `json:"gasPrice"`
+    Gas      uint64 `json:"gas"`
+    Nonce    uint64 `json:"nonce"`
+    Pending  bool   `json:"pending"`
+}
+
+/**
+  *  GetPendingTransaction resolves a transaction hash announced by the
+  *  newPendingTransactions subscription. The transaction may have been mined
+  *  in the meantime, in which case Pending is false. ethereum.NotFound is
+  *  returned once it has left the mempool without being mined.
+  */
+func (bf *BlockFetcher) GetPendingTransaction(ctx context.Context, hash common.Hash) (*PendingTransaction, error) {
+    var result *PendingTransaction
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        tx, pending, err := n.Client.TransactionByHash(ctx, hash)
+        if err != nil {
+            return err
+        }
+        result = &PendingTransaction{
+            BlockTransaction: convertTransaction(tx),
+            GasPrice:         tx.GasPrice().String(),
+            Gas:              tx.Gas(),
+            Nonce:            tx.Nonce(),
+            Pending:          pending,
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch transaction %s: %w", hash.Hex(), err)
+    }
+    return result, nil
+}
+
+/**
+  *  Transaction is a transaction with every field of eth_getTransactionByHash.
+  *  Amounts are wei as decimal strings. GasPrice is set on legacy and access
+  *  list transactions, the fee caps on dynamic fee ones. The block fields are
+  *  empty while the transaction is pending.
+  */
+type Transaction struct {
+    BlockTransaction
+    Type                 uint8            `json:"type"`
+    ChainID              string           `json:"chainId,omitempty"`
+    Nonce                uint64           `json:"nonce"`
+    Gas                  uint64           `json:"gas"`
+    GasPrice             string           `json:"gasPrice,omitempty"`
+    MaxFeePerGas         string           `json:"maxFeePerGas,omitempty"`
+    MaxPriorityFeePerGas string           `json:"maxPriorityFeePerGas,omitempty"`
+    Input                hexutil.Bytes    `json:"input"`
+    AccessList           types.AccessList `json:"accessList,omitempty"`
+    BlobHashes           []common.Hash    `json:"blobVersionedHashes,omitempty"`
+    BlockHash            string           `json:"blockHash,omitempty"`
+    BlockNumber          *uint64          `json:"blockNumber,omitempty"`
+    TransactionIndex     *uint64          `json:"transactionIndex,omitempty"`
+    Pending              bool             `json:"pending"`
+}
+
+// TransactionReceipt is the receipt of a mined transaction. Status is 1 on success and 0 on failure.
+type TransactionReceipt struct {
+    TransactionHash   string       `json:"transactionHash"`
+    BlockHash         string       `json:"blockHash"`
+    BlockNumber       uint64       `json:"blockNumber"`
+    TransactionIndex  uint         `json:"transactionIndex"`
+    Type              uint8        `json:"type"`
+    Status            uint64       `json:"status"`
+    GasUsed           uint64       `json:"gasUsed"`
+    CumulativeGasUsed uint64       `json:"cumulativeGasUsed"`
+    EffectiveGasPrice string       `json:"effectiveGasPrice,omitempty"`
+    ContractAddress   string       `json:"contractAddress,omitempty"`
+    Logs              []*types.Log `json:"logs"`
+}
+
+// TransactionTrace is the raw debug_traceTransaction result and the tracer that produced it.
+type TransactionTrace struct {
+    Tracer string          `json:"tracer"`
+    Result json.RawMessage `json:"result"`
+}
+
+/**
+  *  GetTransaction returns a pending or mined transaction. The block position
+  *  is not exposed by ethclient, so the JSON-RPC answer is decoded directly.
+  */
+func (bf *BlockFetcher) GetTransaction(ctx context.Context, hash common.Hash) (*Transaction, error) {
+    var raw json.RawMessage
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        return n.RPCClient.CallContext(ctx, &raw, "eth_getTransactionByHash", hash)
+    })
+    if err == nil && (len(raw) == 0 || string(raw) == "null") {
+        err = ethereum.NotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch transaction %s: %w", hash.Hex(), err)
+    }
+
+    tx := new(types.Transaction)
+    if err := json.Unmarshal(raw, tx); err != nil {
+        return nil, fmt.Errorf("failed to decode transaction %s: %v", hash.Hex(), err)
+    }
+    var position struct {
+        BlockHash        *common.Hash    `json:"blockHash"`
+        BlockNumber      *hexutil.Uint64 `json:"blockNumber"`
+        TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
+        From             *common.Address `json:"from"`
+    }
+    if err := json.Unmarshal(raw, &position); err != nil {
+        return nil, fmt.Errorf("failed to decode transaction %s: %v", hash.Hex(), err)
+    }
+
+    result := &Transaction{
+        BlockTransaction: convertTransaction(tx),
+        Type:             tx.Type(),
+        Nonce:            tx.Nonce(),
+        Gas:              tx.Gas(),
+        Input:            tx.Data(),
+        AccessList:       tx.AccessList(),
+        BlobHashes:       tx.BlobHashes(),
+        Pending:          position.BlockHash == nil,
+    }
+    if position.From != nil {
+        result.From = position.From.Hex()
+    }
+    if tx.ChainId() != nil && tx.ChainId().Sign() > 0 {
+        result.ChainID = tx.ChainId().String()
+    }
+    switch tx.Type() {
+    case types.LegacyTxType, types.AccessListTxType:
+        result.GasPrice = tx.GasPrice().String()
+    default:
+        result.MaxFeePerGas = tx.GasFeeCap().String()
+        result.MaxPriorityFeePerGas = tx.GasTipCap().String()
+    }
+    if position.BlockHash != nil {
+        result.BlockHash = position.BlockHash.Hex()
+    }
+    if position.BlockNumber != nil {
+        number := uint64(*position.BlockNumber)
+        result.BlockNumber = &number
+    }
+    if position.TransactionIndex != nil {
+        index := uint64(*position.TransactionIndex)
+        result.TransactionIndex = &index
+    }
+    return result, nil
+}
+
+// GetTransactionReceipt returns the receipt of a mined transaction, or ethereum.NotFound while it is pending.
+func (bf *BlockFetcher) GetTransactionReceipt(ctx context.Context, hash common.Hash) (*TransactionReceipt, error) {
+    var receipt *types.Receipt
+    err := bf.pool.Do(ctx, func(n *Node) error {
+        var err error
+        receipt, err = n.Client.TransactionReceipt(ctx, hash)
+        return err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch receipt of %s: %w", hash.Hex(), err)
+    }
+
+    result := &TransactionReceipt{
+        TransactionHash:   receipt.TxHash.Hex(),
+        BlockHash:         receipt.BlockHash.Hex(),
+        BlockNumber:       receipt.BlockNumber.Uint64(),
+        TransactionIndex:  receipt.TransactionIndex,
+        Type:              receipt.Type,
+        Status:            receipt.Status,
+        GasUsed:           receipt.GasUsed,
+        CumulativeGasUsed: receipt.CumulativeGasUsed,
+        Logs:              receipt.Logs,
+    }
+    if receipt.EffectiveGasPrice != nil {
+        result.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+    }
+    if receipt.ContractAddress != (common.Address{}) {
+        result.ContractAddress = receipt.ContractAddress.Hex()
+    }
+    if result.Logs == nil {
+        result.Logs = []*types.Log{}
+    }
+    return result, nil
+}
+
+/**
+  *  TraceTransaction replays a transaction with debug_traceTransaction. The
+  *  call tracer is used when the node provides it, otherwise the default
+  *  struct logger. Nodes without the debug namespace are skipped, and
+  *  ErrTracingNotSupported is returned when none of them exposes it.
+  */
+func (bf *BlockFetcher) TraceTransaction(ctx context.Context, hash common.Hash) (*TransactionTrace, error) {
+    var lastErr error
+    for _, n := range bf.pool.connectedNodes() {
+        trace, err := traceOnNode(ctx, n, hash)
+        if err == nil {
+            return trace, nil
+        }
+        if ctx.Err() != nil {
+            return nil, ctx.Err()
+        }
+        var rpcErr rpc.Error
+        if errors.As(err, &rpcErr) && rpcErr.ErrorCode() != rpcMethodNotFound {
+            if strings.Contains(err.Error(), "transaction not found") {
+                return nil, fmt.Errorf("failed to trace %s: %w", hash.Hex(), ethereum.NotFound)
+            }
+            return nil, fmt.Errorf("failed to trace %s: %v", hash.Hex(), err)
+        }
+        lastErr = err
+    }
+    if lastErr == nil {
+        return nil, ErrNoHealthyNodes
+    }
+    var rpcErr rpc.Error
+    if errors.As(lastErr, &rpcErr) {
+        return nil, ErrTracingNotSupported
+    }
+    return nil, fmt.Errorf("failed to trace %s: %v", hash.Hex(), lastErr)
+}
+
+func traceOnNode(ctx context.Context, n *Node, hash common.Hash) (*TransactionTrace, error) {
+    var result json.RawMessage
+    err := n.RPCClient.CallContext(ctx, &result, "debug_traceTransaction", hash, map[string]interface{}{"tracer": TracerCall})
+    if err == nil {
+        return &TransactionTrace{Tracer: TracerCall, Result: result}, nil
+    }
+    var rpcErr rpc.Error
+    if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() == rpcMethodNotFound || strings.Contains(err.Error(), "transaction not found") {
+        return nil, err
+    }
+    if err := n.RPCClient.CallContext(ctx, &result, "debug_traceTransaction", hash); err != nil {
+        return nil, err
+    }
+    return &TransactionTrace{Tracer: TracerStruct, Result: result}, nil
+}
@@ -0,0 +1,204 @@
+package blockchain
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "sync"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/common/lru"
+    "github.com/ethereum/go-ethereum/rpc"
+    "golang.org/x/sync/singleflight"
+)
+
+// transferCacheSize is the number of blocks whose internal transfers are kept.
+const transferCacheSize = 64
+
+/**
+  *  Transfer is one movement of ether in a block. Top-level transfers are the
+  *  block's value-carrying transactions; Status is nil when the block's
+  *  receipts were unavailable and success could not be checked. Internal
+  *  transfers are value-carrying calls made by contracts, from successful
+  *  frames only.
+  */
+type Transfer struct {
+    TxHash      string  `json:"txHash"`
+    BlockNumber uint64  `json:"blockNumber"`
+    BlockHash   string  `json:"blockHash"`
+    From        string  `json:"from"`
+    To          string  `json:"to"`
+    ValueWei    string  `json:"valueWei"`
+    ValueEther  string  `json:"valueEther"`
+    Internal    bool    `json:"internal"`
+    Status      *uint64 `json:"status,omitempty"`
+    // Removed is set when the block holding the transfer was dropped by a reorg.
+    Removed     bool    `json:"removed,omitempty"`
+}
+
+// callFrame is one frame of a callTracer result.
+type callFrame struct {
+    Type  string         `json:"type"`
+    From  common.Address `json:"from"`
+    To    common.Address `json:"to"`
+    Value *hexutil.Big   `json:"value"`
+    Error string         `json:"error"`
+    Calls []callFrame    `json:"calls"`
+}
+
+type blockTraceResult struct {
+    Result callFrame `json:"result"`
+    Error  string    `json:"error"`
+}
+
+// transferCache keeps the traced internal transfers of recent blocks, sharing one trace between concurrent callers.
+type transferCache struct {
+    mu       sync.Mutex
+    byHash   lru.BasicLRU[common.Hash, []Transfer]
+    inFlight singleflight.Group
+}
+
+func newTransferCache() *transferCache {
+    return &transferCache{byHash: lru.NewBasicLRU[common.Hash, []Transfer](transferCacheSize)}
+}
+
+/**
+  *  BlockTransfers lists the top-level transfers of a converted block. Zero
+  *  value transactions move no ether and failed ones moved none, so both are
+  *  left out.
+  */
+func BlockTransfers(block *Block) []Transfer {
+    var transfers []Transfer
+    for _, tx := range block.Transactions {
+        if tx.ValueWei == "0" || (tx.Status != nil && *tx.Status == 0) {
+            continue
+        }
+        transfers = append(transfers, Transfer{
+            TxHash:      tx.Hash,
+            BlockNumber: block.Number,
+            BlockHash:   block.Hash,
+            From:        tx.From,
+            To:          tx.To,
+            ValueWei:    tx.ValueWei,
+            ValueEther:  tx.ValueEther,
+            Status:      tx.Status,
+        })
+    }
+    return transfers
+}
+
+/**
+  *  InternalTransfers traces block with debug_traceBlockByHash and the call
+  *  tracer and returns every value-carrying call below the top-level frames.
+  *  Calls in reverted frames are skipped, as are delegate and static calls,
+  *  which move no ether. Nodes without the debug namespace are skipped;
+  *  ErrTracingNotSupported is returned only when every node answered without
+  *  it, and a transport failure is returned as is so callers can retry.
+  */
+func (bf *BlockFetcher) InternalTransfers(ctx context.Context, block *Block) ([]Transfer, error) {
+    hash := common.HexToHash(block.Hash)
+    bf.transfers.mu.Lock()
+    transfers, ok := bf.transfers.byHash.Get(hash)
+    bf.transfers.mu.Unlock()
+    if ok {
+        return transfers, nil
+    }
+
+    ch := bf.transfers.inFlight.DoChan(block.Hash, func() (interface{}, error) {
+        transfers, err := bf.traceTransfers(context.WithoutCancel(ctx), block)
+        if err != nil {
+            return nil, err
+        }
+        bf.transfers.mu.Lock()
+        bf.transfers.byHash.Add(hash, transfers)
+        bf.transfers.mu.Unlock()
+        return transfers, nil
+    })
+    select {
+    case res := <-ch:
+        if res.Err != nil {
+            return nil, res.Err
+        }
+        return res.Val.([]Transfer), nil
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+}
+
+func (bf *BlockFetcher) traceTransfers(ctx context.Context, block *Block) ([]Transfer, error) {
+    if len(block.Transactions) == 0 {
+        return nil, nil
+    }
+
+    // A node that lacks the method rules itself out; one that could not be reached may still have it.
+    var results []blockTraceResult
+    var transportErr error
+    unsupported := 0
+    traced := false
+    for _, n := range bf.pool.connectedNodes() {
+        err := n.RPCClient.CallContext(ctx, &results, "debug_traceBlockByHash", common.HexToHash(block.Hash), map[string]interface{}{"tracer": TracerCall})
+        if err == nil {
+            traced = true
+            break
+        }
+        if ctx.Err() != nil {
+            return nil, ctx.Err()
+        }
+        var rpcErr rpc.Error
+        if !errors.As(err, &rpcErr) {
+            transportErr = err
+            continue
+        }
+        if rpcErr.ErrorCode() != rpcMethodNotFound {
+            return nil, fmt.Errorf("failed to trace block %d: %v", block.Number, err)
+        }
+        unsupported++
+    }
+    if !traced {
+        if transportErr != nil {
+            return nil, fmt.Errorf("failed to trace block %d: %v", block.Number, transportErr)
+        }
+        if unsupported == 0 {
+            return nil, ErrNoHealthyNodes
+        }
+        return nil, ErrTracingNotSupported
+    }
+    if len(results) != len(block.Transactions) {
+        return nil, fmt.Errorf("trace of block %d has %d results for %d transactions", block.Number, len(results), len(block.Transactions))
+    }
+
+    var transfers []Transfer
+    for i, res := range results {
+        if res.Error != "" || res.Result.Error != "" {
+            continue
+        }
+        tx := block.Transactions[i]
+        var walk func(frames []callFrame)
+        walk = func(frames []callFrame) {
+            for _, frame := range frames {
+                if frame.Error != "" {
+                    continue
+                }
+                kind := strings.ToUpper(frame.Type)
+                if kind != "DELEGATECALL" && kind != "STATICCALL" && frame.Value != nil && frame.Value.ToInt().Sign() > 0 {
+                    value := frame.Value.ToInt()
+                    transfers = append(transfers, Transfer{
+                        TxHash:      tx.Hash,
+                        BlockNumber: block.Number,
+                        BlockHash:   block.Hash,
+                        From:        frame.From.Hex(),
+                        To:          frame.To.Hex(),
+                        ValueWei:    value.String(),
+                        ValueEther:  weiToEther(value),
+                        Internal:    true,
+                    })
+                }
+                walk(frame.Calls)
+            }
+        }
+        walk(res.Result.Calls)
+    }
+    return transfers, nil
+}
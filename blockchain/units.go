@@ -0,0 +1,55 @@
+package blockchain
+
+import (
+    "math/big"
+    "strings"
+)
+
+const (
+    gweiDecimals  = 9
+    etherDecimals = 18
+)
+
+/**
+  *  formatUnits renders amount / 10^decimals as an exact decimal string,
+  *  without trailing fractional zeros: 1500000000 with 9 decimals is "1.5".
+  */
+func formatUnits(amount *big.Int, decimals int) string {
+    if amount == nil {
+        return "0"
+    }
+    digits := new(big.Int).Abs(amount).String()
+    if len(digits) <= decimals {
+        digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+    }
+    whole := digits[:len(digits)-decimals]
+    fraction := strings.TrimRight(digits[len(digits)-decimals:], "0")
+    if amount.Sign() < 0 {
+        whole = "-" + whole
+    }
+    if fraction == "" {
+        return whole
+    }
+    return whole + "." + fraction
+}
+
+func weiToGwei(wei *big.Int) string {
+    return formatUnits(wei, gweiDecimals)
+}
+
+func weiToEther(wei *big.Int) string {
+    return formatUnits(wei, etherDecimals)
+}
+
+// FormatEther renders an amount of wei as an exact decimal ether string.
+func FormatEther(wei *big.Int) string {
+    return weiToEther(wei)
+}
+
+// weiToEtherFloat backs the deprecated float64 fields; it loses precision.
+func weiToEtherFloat(wei *big.Int) float64 {
+    weiPerEth := new(big.Float).SetInt(big.NewInt(1e18))
+    valueEth := new(big.Float).Quo(new(big.Float).SetInt(wei), weiPerEth)
+    value, _ := valueEth.Float64()
+    return value
+}
@@ -7,6 +7,7 @@ require (
 	github.com/ethereum/go-ethereum v1.15.10
 	github.com/gorilla/mux v1.8.1
 	github.com/gorilla/websocket v1.5.3
+	golang.org/x/sync v0.11.0
 )
 
 require (
@@ -57,7 +58,6 @@ require (
 	golang.org/x/crypto v0.35.0 // indirect
 	golang.org/x/image v0.24.0 // indirect
 	golang.org/x/net v0.36.0 // indirect
-	golang.org/x/sync v0.11.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
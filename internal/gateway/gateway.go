@@ -1,41 +1,67 @@
 /*
 ==========================================================================================
   File:        gateway.go
-  Last Update: 2024-05-18
+  Last Update: 2026-10-17
   Author:      Haitam Bidiouane (@sh0penheimer)
   Ownership:   © Haitam Bidiouane. All rights reserved.
 ------------------------------------------------------------------------------------------
   Scope:
     Provides the Gateway struct and orchestration logic for the blockchain websocket gateway.
     Acts as a facade for configuration, startup, shutdown, and status, using the blockchain
-    and websocket packages. Designed for use by both CLI and GUI entry points.
+    and websocket packages. Owns the HTTP server and the full component lifecycle so the
+    gateway can be stopped and restarted in-process. Designed for use by both CLI and GUI
+    entry points.
 ==========================================================================================
 */
 
 package gateway
 
 import (
+    "context"
+    "errors"
     "fmt"
+    "log"
+    "net"
+    "net/http"
     "strings"
+    "sync"
+
+    "github.com/gorilla/mux"
     "github.com/sch0penheimer/eth-ws-server/blockchain"
     "github.com/sch0penheimer/eth-ws-server/websocket"
 )
 
+const defaultListenAddr = ":8080"
+
 type GatewayConfig struct {
     NodeCount     int
     NodeAddresses []string
     NodePorts     []string
-    // Add more config fields as needed (e.g., listen port, log level, etc.)
+    // NodeSchemes optionally sets the RPC scheme (ws, wss, http, https) of each node.
+    // When empty, node 0 is dialed over ws and the others over http.
+    NodeSchemes   []string
+    // NodeStrategy selects how reads are spread over healthy nodes
+    // (blockchain.StrategyRoundRobin or blockchain.StrategyLowestLatency).
+    NodeStrategy  string
+    // ListenAddr is the HTTP listen address, ":8080" when empty.
+    ListenAddr    string
+    // Fetcher tunes block, log and metrics queries; zero values select the defaults.
+    Fetcher       blockchain.FetcherConfig
 }
 
 type Gateway struct {
     config           GatewayConfig
+    nodeURLs         []string
     blockFetcher     *blockchain.BlockFetcher
     miningController *blockchain.MiningController
     wsHandler        *websocket.WSHandler
+    server           *http.Server
+    serveDone        chan struct{}
     running          bool
+    mu               sync.Mutex
 }
 
+// NewGateway validates the configuration. Node connections are opened by Start.
 func NewGateway(cfg GatewayConfig) (*Gateway, error) {
     if cfg.NodeCount <= 0 {
         return nil, fmt.Errorf("invalid node count")
@@ -43,64 +69,173 @@ func NewGateway(cfg GatewayConfig) (*Gateway, error) {
     if len(cfg.NodeAddresses) != cfg.NodeCount || len(cfg.NodePorts) != cfg.NodeCount {
         return nil, fmt.Errorf("addresses and ports must match node count")
     }
+    if len(cfg.NodeSchemes) != 0 && len(cfg.NodeSchemes) != cfg.NodeCount {
+        return nil, fmt.Errorf("schemes must match node count")
+    }
+    if err := blockchain.ValidateConsensus(cfg.Fetcher.Consensus); err != nil {
+        return nil, err
+    }
+    if cfg.ListenAddr == "" {
+        cfg.ListenAddr = defaultListenAddr
+    }
     nodeURLs := make([]string, cfg.NodeCount)
     for i := 0; i < cfg.NodeCount; i++ {
-        if i == 0 {
-            nodeURLs[i] = fmt.Sprintf("ws://%s:%s", cfg.NodeAddresses[i], cfg.NodePorts[i])
-        } else {
-            nodeURLs[i] = fmt.Sprintf("http://%s:%s", cfg.NodeAddresses[i], cfg.NodePorts[i])
+        scheme := "http"
+        if len(cfg.NodeSchemes) != 0 {
+            scheme = cfg.NodeSchemes[i]
+        } else if i == 0 {
+            scheme = "ws"
         }
+        switch scheme {
+        case "ws", "wss", "http", "https":
+        default:
+            return nil, fmt.Errorf("unsupported scheme %q for node %d", scheme, i)
+        }
+        nodeURLs[i] = fmt.Sprintf("%s://%s:%s", scheme, cfg.NodeAddresses[i], cfg.NodePorts[i])
+    }
+    return &Gateway{
+        config:   cfg,
+        nodeURLs: nodeURLs,
+        running:  false,
+    }, nil
+}
+
+/**
+  *  Start connects to the configured nodes, starts the websocket handler and
+  *  binds the HTTP listener. It returns once the listener is accepting
+  *  connections; ctx only bounds the initial node dials. A stopped gateway
+  *  can be started again.
+  */
+func (g *Gateway) Start(ctx context.Context) error {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+
+    if g.running {
+        return fmt.Errorf("gateway is already running")
+    }
+
+    miningController, err := blockchain.NewMiningController(ctx, g.nodeURLs)
+    if err != nil {
+        return fmt.Errorf("failed to initialize mining controller: %w", err)
     }
-    miningController, err := blockchain.NewMiningController(nodeURLs)
+    nodePool, err := blockchain.NewNodePool(ctx, g.nodeURLs, g.config.NodeStrategy)
     if err != nil {
-        return nil, fmt.Errorf("failed to initialize mining controller: %w", err)
+        miningController.Close()
+        return fmt.Errorf("failed to initialize node pool: %w", err)
     }
-    blockFetcher, err := blockchain.NewBlockFetcher(nodeURLs[0])
+    blockFetcher := blockchain.NewBlockFetcher(nodePool, g.config.Fetcher)
+
+    listener, err := net.Listen("tcp", g.config.ListenAddr)
     if err != nil {
-        return nil, fmt.Errorf("failed to initialize block fetcher: %w", err)
+        blockFetcher.Close()
+        miningController.Close()
+        return fmt.Errorf("failed to listen on %s: %w", g.config.ListenAddr, err)
     }
+
     wsHandler := websocket.NewWSHandler(blockFetcher, miningController)
-    return &Gateway{
-        config:           cfg,
-        blockFetcher:     blockFetcher,
-        miningController: miningController,
-        wsHandler:        wsHandler,
-        running:          false,
-    }, nil
-}
+    server := &http.Server{Handler: newRouter(wsHandler)}
+    serveDone := make(chan struct{})
+    go func() {
+        defer close(serveDone)
+        log.Printf("Server starting on %s", listener.Addr())
+        if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            log.Printf("HTTP server error: %v", err)
+        }
+    }()
 
-// Start launches the gateway (placeholder for future expansion)
-func (g *Gateway) Start() error {
+    g.blockFetcher = blockFetcher
+    g.miningController = miningController
+    g.wsHandler = wsHandler
+    g.server = server
+    g.serveDone = serveDone
     g.running = true
-    // In CLI: would start HTTP server, etc. In GUI: would trigger server start.
     return nil
 }
 
-// Stop shuts down the gateway (placeholder for future expansion)
-func (g *Gateway) Stop() error {
+/**
+  *  Stop shuts the HTTP server down, drains websocket clients, cancels the
+  *  head subscription and closes every node connection. It returns when all
+  *  gateway goroutines have exited or ctx expires, whichever comes first.
+  */
+func (g *Gateway) Stop(ctx context.Context) error {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+
+    if !g.running {
+        return nil
+    }
+
+    var errs []error
+    if err := g.server.Shutdown(ctx); err != nil {
+        errs = append(errs, fmt.Errorf("http server shutdown: %w", err))
+    }
+    if err := g.wsHandler.Shutdown(ctx); err != nil {
+        errs = append(errs, fmt.Errorf("websocket shutdown: %w", err))
+    }
+    g.blockFetcher.Close()
+    g.miningController.Close()
+
+    select {
+    case <-g.serveDone:
+    case <-ctx.Done():
+        errs = append(errs, ctx.Err())
+    }
+
+    g.blockFetcher = nil
+    g.miningController = nil
+    g.wsHandler = nil
+    g.server = nil
     g.running = false
-    // Add logic to gracefully stop HTTP server, close connections, etc.
-    return nil
+    return errors.Join(errs...)
 }
 
 // Status returns a string summary of the gateway's current state
 func (g *Gateway) Status() string {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+
     status := "stopped"
     if g.running {
         status = "running"
     }
-    return fmt.Sprintf("Gateway status: %s | Nodes: %d | Addresses: %s | Ports: %s", status, g.config.NodeCount, strings.Join(g.config.NodeAddresses, ","), strings.Join(g.config.NodePorts, ","))
+    return fmt.Sprintf("Gateway status: %s | Listen: %s | Nodes: %d | Addresses: %s | Ports: %s", status, g.config.ListenAddr, g.config.NodeCount, strings.Join(g.config.NodeAddresses, ","), strings.Join(g.config.NodePorts, ","))
 }
 
-// Expose accessors for blockFetcher, miningController, wsHandler as needed for CLI/GUI
-func (g *Gateway) BlockFetcher() *blockchain.BlockFetcher { return g.blockFetcher }
-func (g *Gateway) MiningController() *blockchain.MiningController { return g.miningController }
-func (g *Gateway) WSHandler() *websocket.WSHandler { return g.wsHandler }
-
-
-
-
-
+// Expose accessors for blockFetcher, miningController, wsHandler as needed for CLI/GUI.
+// They return nil while the gateway is stopped.
+func (g *Gateway) BlockFetcher() *blockchain.BlockFetcher {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    return g.blockFetcher
+}
 
+func (g *Gateway) MiningController() *blockchain.MiningController {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    return g.miningController
+}
 
+func (g *Gateway) WSHandler() *websocket.WSHandler {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    return g.wsHandler
+}
 
+func newRouter(wsHandler *websocket.WSHandler) http.Handler {
+    r := mux.NewRouter()
+    r.HandleFunc("/ws", wsHandler.HandleConnections)
+    r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte("OK"))
+    })
+
+    //-- CORS middleware --//
+    r.Use(func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Set("Access-Control-Allow-Origin", "*")
+            w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+            w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+            next.ServeHTTP(w, r)
+        })
+    })
+    return r
+}
@@ -1,34 +1,40 @@
 /*
 ==========================================================================================
   File:        main.go
-  Last Update: 2024-05-18
+  Last Update: 2026-10-17
   Author:      Haitam Bidiouane (@sh0penheimer)
   Ownership:   © Haitam Bidiouane. All rights reserved.
 ------------------------------------------------------------------------------------------
   Scope:
     CLI entry point for the blockchain websocket gateway. Parses command-line flags,
-    initializes the Gateway orchestration layer, and starts the HTTP server for websocket
-    and health endpoints. Designed to be used as a CLI or as a reference for GUI startup.
+    initializes the Gateway orchestration layer, which serves the websocket and health
+    endpoints, and stops it gracefully on SIGINT/SIGTERM. Designed to be used as a CLI or
+    as a reference for GUI startup.
 ==========================================================================================
 */
 
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"log"
-	"net/http"
 	"strings"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/sch0penheimer/eth-ws-server/blockchain"
 	"github.com/sch0penheimer/eth-ws-server/internal/gateway"
 )
 
+const shutdownTimeout = 15 * time.Second
+
 func printUsage() {
 	fmt.Fprintf(os.Stderr, `\nBlockchain Websocket Gateway\n\n`)
-	fmt.Fprintf(os.Stderr, `Usage: %s --nodes N --addresses IP1,IP2,... --ports PORT1,PORT2,...\n`, os.Args[0])
+	fmt.Fprintf(os.Stderr, `Usage: %s --nodes N --addresses IP1,IP2,... --ports PORT1,PORT2,... [--schemes ws,http,...] [--strategy round-robin|lowest-latency] [--consensus clique|ibft|qbft|ethash|pos]\n`, os.Args[0])
 	fmt.Fprintf(os.Stderr, `\nFlags:\n`)
 	flag.PrintDefaults()
 }
@@ -37,6 +43,17 @@ func main() {
 	nodeCount := flag.Int("nodes", 0, "Total number of nodes (required)")
 	nodeAddresses := flag.String("addresses", "", "Comma-separated list of node IP addresses (required)")
 	nodePorts := flag.String("ports", "", "Comma-separated list of node ports (required)")
+	nodeSchemes := flag.String("schemes", "", "Comma-separated list of node RPC schemes: ws, wss, http, https (default: ws for the first node, http for the others)")
+	listenAddr := flag.String("listen", ":8080", "HTTP listen address for the websocket and health endpoints")
+	maxLogRange := flag.Uint64("max-log-range", 2000, "Maximum number of blocks a single getLogs request may span")
+	blockTimeWindow := flag.Int("block-time-window", 64, "Number of recent blocks covered by the block time statistics")
+	blockCacheSize := flag.Int("block-cache-size", 256, "Number of converted blocks kept in memory")
+	metricsInterval := flag.Duration("metrics-interval", 15*time.Second, "How often network metrics are refreshed")
+	consensus := flag.String("consensus", "", "Consensus engine used for validator lookups: clique, ibft, qbft, ethash or pos (default: detected from the node)")
+	cliqueRPCFallback := flag.Bool("clique-rpc-fallback", false, "Resolve Clique signers with clique_getSigner when they cannot be recovered from the header")
+	cliqueEpoch := flag.Uint64("clique-epoch", 30000, "Clique checkpoint interval, used to detect signer set changes")
+	beaconURL := flag.String("beacon-url", "", "Beacon node REST endpoint used to resolve post-merge block proposers")
+	nodeStrategy := flag.String("strategy", "round-robin", "Read routing strategy across healthy nodes: round-robin or lowest-latency")
 	help := flag.Bool("help", false, "Show help message")
 	flag.Usage = printUsage
 	flag.Parse()
@@ -66,35 +83,53 @@ func main() {
 		os.Exit(1)
 	}
 
+	var schemeList []string
+	if *nodeSchemes != "" {
+		schemeList = strings.Split(*nodeSchemes, ",")
+		if len(schemeList) != *nodeCount {
+			fmt.Fprintf(os.Stderr, "Error: The number of schemes (%d) must match the node count (%d).\n", len(schemeList), *nodeCount)
+			printUsage()
+			os.Exit(1)
+		}
+	}
+
 	cfg := gateway.GatewayConfig{
 		NodeCount:     *nodeCount,
 		NodeAddresses: addressList,
 		NodePorts:     portList,
+		NodeSchemes:   schemeList,
+		NodeStrategy:  *nodeStrategy,
+		ListenAddr:    *listenAddr,
+		Fetcher: blockchain.FetcherConfig{
+			MaxLogBlockRange:  *maxLogRange,
+			Consensus:         *consensus,
+			BeaconURL:         *beaconURL,
+			CliqueRPCFallback: *cliqueRPCFallback,
+			CliqueEpoch:       *cliqueEpoch,
+			BlockTimeWindow:   *blockTimeWindow,
+			MetricsInterval:   *metricsInterval,
+			BlockCacheSize:    *blockCacheSize,
+		},
 	}
 	gw, err := gateway.NewGateway(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize gateway: %v", err)
 	}
-	gw.Start()
-	log.Println(gw.Status())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Set up the HTTP server
-	r := mux.NewRouter()
-	r.HandleFunc("/ws", gw.WSHandler().HandleConnections)
-	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("OK"))
-	})
+	if err := gw.Start(ctx); err != nil {
+		log.Fatalf("Failed to start gateway: %v", err)
+	}
+	log.Println(gw.Status())
 
-	// Add CORS middleware
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-			next.ServeHTTP(w, r)
-		})
-	})
+	<-ctx.Done()
+	log.Println("Shutting down gateway...")
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := gw.Stop(shutdownCtx); err != nil {
+		log.Printf("Gateway did not shut down cleanly: %v", err)
+	}
+	log.Println(gw.Status())
 }
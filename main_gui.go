@@ -15,10 +15,12 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
@@ -90,7 +92,12 @@ func main() {
 				errors.New(gwErr.Error()), w)
 			return
 		}
-		gw.Start()
+		if err := gw.Start(context.Background()); err != nil {
+			statusLabel.SetText("Status: error")
+			appendLog(fmt.Sprintf("[ERROR] %s", err.Error()))
+			dialog.ShowError(err, w)
+			return
+		}
 		statusLabel.SetText(gw.Status())
 		appendLog("[INFO] Gateway started.")
 		startBtn.Disable()
@@ -98,7 +105,11 @@ func main() {
 	})
 	stopBtn = widget.NewButton("Stop Gateway", func() {
 		if gw != nil {
-			gw.Stop()
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			if err := gw.Stop(ctx); err != nil {
+				appendLog(fmt.Sprintf("[WARN] Gateway did not stop cleanly: %s", err.Error()))
+			}
 			statusLabel.SetText(gw.Status())
 			appendLog("[INFO] Gateway stopped.")
 		}
@@ -0,0 +1,203 @@
+package websocket
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/sch0penheimer/eth-ws-server/blockchain"
+)
+
+/**
+  *  AccountRequest selects one account at a block number or tag, latest by
+  *  default. Slot is only used by getStorageAt: a 0x-prefixed hex quantity of
+  *  at most 32 bytes.
+  */
+type AccountRequest struct {
+    Address *common.Address            `json:"address"`
+    Slot    string                     `json:"slot"`
+    Block   *blockchain.BlockNumberArg `json:"block"`
+}
+
+// AccountsRequest selects many accounts at one block; their code is included when Code is set.
+type AccountsRequest struct {
+    Addresses []common.Address           `json:"addresses"`
+    Block     *blockchain.BlockNumberArg `json:"block"`
+    Code      bool                       `json:"code"`
+}
+
+// parseAccountRequest decodes a single account query, replying with an error when the address is missing or invalid.
+func (h *WSHandler) parseAccountRequest(client *Client, msg WSMessage) (AccountRequest, blockchain.BlockNumberArg, bool) {
+    var req AccountRequest
+    if err := json.Unmarshal(msg.Payload, &req); err != nil {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format: "+err.Error())
+        return req, 0, false
+    }
+    if req.Address == nil {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "address is required")
+        return req, 0, false
+    }
+    block := blockchain.LatestBlock
+    if req.Block != nil {
+        block = *req.Block
+    }
+    return req, block, true
+}
+
+// parseStorageSlot accepts a slot as a 0x-prefixed hex quantity of up to 32 bytes, left-padding it to a full word.
+func parseStorageSlot(s string) (common.Hash, error) {
+    digits, ok := strings.CutPrefix(s, "0x")
+    if !ok || digits == "" || len(digits) > 2*common.HashLength {
+        return common.Hash{}, fmt.Errorf("invalid storage slot %q", s)
+    }
+    if len(digits)%2 == 1 {
+        digits = "0" + digits
+    }
+    b, err := hexutil.Decode("0x" + digits)
+    if err != nil {
+        return common.Hash{}, fmt.Errorf("invalid storage slot %q", s)
+    }
+    return common.BytesToHash(b), nil
+}
+
+func (h *WSHandler) handleGetBalance(client *Client, msg WSMessage) {
+    req, block, ok := h.parseAccountRequest(client, msg)
+    if !ok {
+        return
+    }
+
+    balance, err := h.blockFetcher.GetBalance(h.ctx, *req.Address, block)
+    if err != nil {
+        log.Printf("Error fetching balance: %v", err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch balance")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "balance",
+        "data": map[string]interface{}{
+            "address":      req.Address.Hex(),
+            "block":        block.String(),
+            "balanceWei":   balance.String(),
+            "balanceEther": blockchain.FormatEther(balance),
+        },
+    })
+}
+
+func (h *WSHandler) handleGetTransactionCount(client *Client, msg WSMessage) {
+    req, block, ok := h.parseAccountRequest(client, msg)
+    if !ok {
+        return
+    }
+
+    nonce, err := h.blockFetcher.GetTransactionCount(h.ctx, *req.Address, block)
+    if err != nil {
+        log.Printf("Error fetching transaction count: %v", err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch transaction count")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "transactionCount",
+        "data": map[string]interface{}{
+            "address": req.Address.Hex(),
+            "block":   block.String(),
+            "nonce":   nonce,
+        },
+    })
+}
+
+func (h *WSHandler) handleGetCode(client *Client, msg WSMessage) {
+    req, block, ok := h.parseAccountRequest(client, msg)
+    if !ok {
+        return
+    }
+
+    code, err := h.blockFetcher.GetCode(h.ctx, *req.Address, block)
+    if err != nil {
+        log.Printf("Error fetching code: %v", err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch code")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "code",
+        "data": map[string]interface{}{
+            "address": req.Address.Hex(),
+            "block":   block.String(),
+            "code":    hexutil.Encode(code),
+        },
+    })
+}
+
+func (h *WSHandler) handleGetStorageAt(client *Client, msg WSMessage) {
+    req, block, ok := h.parseAccountRequest(client, msg)
+    if !ok {
+        return
+    }
+    if req.Slot == "" {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "slot is required")
+        return
+    }
+    slot, err := parseStorageSlot(req.Slot)
+    if err != nil {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, err.Error())
+        return
+    }
+
+    value, err := h.blockFetcher.GetStorageAt(h.ctx, *req.Address, slot, block)
+    if err != nil {
+        log.Printf("Error fetching storage: %v", err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch storage")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "storage",
+        "data": map[string]interface{}{
+            "address": req.Address.Hex(),
+            "block":   block.String(),
+            "slot":    slot.Hex(),
+            "value":   value.Hex(),
+        },
+    })
+}
+
+func (h *WSHandler) handleGetAccounts(client *Client, msg WSMessage) {
+    var req AccountsRequest
+    if err := json.Unmarshal(msg.Payload, &req); err != nil {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format: "+err.Error())
+        return
+    }
+    if len(req.Addresses) == 0 {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "addresses is required")
+        return
+    }
+    block := blockchain.LatestBlock
+    if req.Block != nil {
+        block = *req.Block
+    }
+
+    accounts, err := h.blockFetcher.GetAccounts(h.ctx, req.Addresses, block, req.Code)
+    if err != nil {
+        if errors.Is(err, blockchain.ErrTooManyAccounts) {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, err.Error())
+            return
+        }
+        log.Printf("Error fetching accounts: %v", err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch accounts")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "accounts",
+        "data": map[string]interface{}{
+            "block":    block.String(),
+            "accounts": accounts,
+        },
+    })
+}
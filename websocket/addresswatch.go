@@ -0,0 +1,268 @@
+package websocket
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "math/big"
+    "time"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/sch0penheimer/eth-ws-server/blockchain"
+)
+
+const (
+    defaultBalanceInterval = 60 * time.Second
+    minBalanceInterval     = 5 * time.Second
+    maxAddressWatches      = 16 // per client
+    addressWatchHistory    = 64 // blocks whose transfers can be retracted after a reorg
+)
+
+const (
+    TransferIn   = "in"
+    TransferOut  = "out"
+    TransferSelf = "self"
+)
+
+/**
+  *  WatchAddressParams selects the accounts to follow, at most
+  *  blockchain.MaxAccountsPerRequest of them. Internal also reports value
+  *  transfers made by contracts, which needs the debug namespace on a node.
+  *  BalanceInterval is the number of seconds between balance checks (60 by
+  *  default, at least 5); 0 disables them.
+  */
+type WatchAddressParams struct {
+    Addresses       []common.Address `json:"addresses"`
+    Internal        bool             `json:"internal"`
+    BalanceInterval *uint64          `json:"balanceInterval"`
+}
+
+// AddressTransfer is the data of an addressTransfer notification. Direction is relative to the watched addresses.
+type AddressTransfer struct {
+    blockchain.Transfer
+    Direction string `json:"direction"`
+}
+
+// BalanceDelta is the change of one watched balance since the previous check.
+type BalanceDelta struct {
+    Address      string `json:"address"`
+    BalanceWei   string `json:"balanceWei"`
+    BalanceEther string `json:"balanceEther"`
+    DeltaWei     string `json:"deltaWei"`
+    DeltaEther   string `json:"deltaEther"`
+}
+
+type addressWatch struct {
+    addresses       []common.Address
+    watched         map[common.Address]bool
+    internal        bool
+    balanceInterval time.Duration
+}
+
+func parseAddressWatchParams(raw json.RawMessage) (addressWatch, error) {
+    var p WatchAddressParams
+    if len(raw) > 0 {
+        if err := json.Unmarshal(raw, &p); err != nil {
+            return addressWatch{}, fmt.Errorf("invalid watchAddress params: %v", err)
+        }
+    }
+    if len(p.Addresses) == 0 {
+        return addressWatch{}, errors.New("watchAddress requires at least one address")
+    }
+    if len(p.Addresses) > blockchain.MaxAccountsPerRequest {
+        return addressWatch{}, fmt.Errorf("at most %d addresses can be watched per subscription", blockchain.MaxAccountsPerRequest)
+    }
+    w := addressWatch{
+        watched:         make(map[common.Address]bool, len(p.Addresses)),
+        internal:        p.Internal,
+        balanceInterval: defaultBalanceInterval,
+    }
+    for _, address := range p.Addresses {
+        if !w.watched[address] {
+            w.watched[address] = true
+            w.addresses = append(w.addresses, address)
+        }
+    }
+    if p.BalanceInterval != nil {
+        w.balanceInterval = time.Duration(*p.BalanceInterval) * time.Second
+        if w.balanceInterval != 0 && w.balanceInterval < minBalanceInterval {
+            return addressWatch{}, fmt.Errorf("balanceInterval must be 0 or at least %d seconds", int(minBalanceInterval.Seconds()))
+        }
+    }
+    return w, nil
+}
+
+// direction reports how transfer touches the watched addresses, or "" when it does not.
+func (w addressWatch) direction(transfer blockchain.Transfer) string {
+    from := w.watched[common.HexToAddress(transfer.From)]
+    to := w.watched[common.HexToAddress(transfer.To)]
+    switch {
+    case from && to:
+        return TransferSelf
+    case from:
+        return TransferOut
+    case to:
+        return TransferIn
+    }
+    return ""
+}
+
+/**
+  *  runAddressWatch follows the watched addresses through the head stream.
+  *  Every transaction of a new block sent from or to one of them is pushed as
+  *  an addressTransfer, followed by the matching internal transfers when
+  *  requested. Transfers of blocks dropped by a reorg are sent again with
+  *  removed set, and blocks skipped by the head tracker are reported as a
+  *  gap. Balances are checked every balanceInterval and only the ones that
+  *  changed since the previous check are pushed, as balanceDelta.
+  */
+func (h *WSHandler) runAddressWatch(ctx context.Context, sub *subscription, w addressWatch) {
+    var balanceTicks <-chan time.Time
+    var balances map[common.Address]*big.Int
+    if w.balanceInterval > 0 {
+        ticker := time.NewTicker(w.balanceInterval)
+        defer ticker.Stop()
+        balanceTicks = ticker.C
+        balances = h.checkBalances(ctx, sub, w, nil)
+    }
+
+    sent := make(map[string][]blockchain.Transfer)
+    var order []string
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-balanceTicks:
+            if latest := h.checkBalances(ctx, sub, w, balances); latest != nil {
+                balances = latest
+            }
+        case ev := <-sub.heads:
+            if ev.Reorg != nil {
+                for _, removed := range ev.Reorg.Removed {
+                    for _, transfer := range sent[removed.Hash] {
+                        transfer.Removed = true
+                        h.notify(sub, map[string]interface{}{
+                            "type": "addressTransfer",
+                            "data": AddressTransfer{Transfer: transfer, Direction: w.direction(transfer)},
+                        })
+                    }
+                    delete(sent, removed.Hash)
+                }
+                continue
+            }
+            if ev.Gap != nil {
+                // Transfers in skipped blocks are not scanned; tell the client which ones.
+                h.notify(sub, map[string]interface{}{
+                    "type": "gap",
+                    "data": ev.Gap,
+                })
+                continue
+            }
+
+            block, err := h.blockFetcher.GetBlockByHash(ctx, ev.Header.Hash())
+            if err != nil {
+                if ctx.Err() == nil {
+                    log.Printf("Error fetching block %d for address watch %s: %v", ev.Header.Number, sub.id, err)
+                }
+                continue
+            }
+            transfers := blockchain.BlockTransfers(block)
+            if w.internal {
+                internal, err := h.blockFetcher.InternalTransfers(ctx, block)
+                switch {
+                case errors.Is(err, blockchain.ErrTracingNotSupported):
+                    w.internal = false
+                    h.notify(sub, map[string]interface{}{
+                        "type": "addressWatchWarning",
+                        "data": map[string]string{"message": "internal transfers disabled: no configured node exposes debug_traceBlockByHash"},
+                    })
+                case err != nil:
+                    if ctx.Err() == nil {
+                        log.Printf("Error tracing block %d for address watch %s: %v", block.Number, sub.id, err)
+                    }
+                default:
+                    transfers = append(transfers, internal...)
+                }
+            }
+
+            var matched []blockchain.Transfer
+            for _, transfer := range transfers {
+                direction := w.direction(transfer)
+                if direction == "" {
+                    continue
+                }
+                matched = append(matched, transfer)
+                h.notify(sub, map[string]interface{}{
+                    "type": "addressTransfer",
+                    "data": AddressTransfer{Transfer: transfer, Direction: direction},
+                })
+            }
+            if len(matched) == 0 {
+                continue
+            }
+            sent[block.Hash] = matched
+            order = append(order, block.Hash)
+            if len(order) > addressWatchHistory {
+                delete(sent, order[0])
+                order = order[1:]
+            }
+        }
+    }
+}
+
+/**
+  *  checkBalances reads the watched balances at the latest block and pushes
+  *  the ones that differ from previous. It returns the balances read, or nil
+  *  when the check failed. A nil previous only records the baseline.
+  */
+func (h *WSHandler) checkBalances(ctx context.Context, sub *subscription, w addressWatch, previous map[common.Address]*big.Int) map[common.Address]*big.Int {
+    header, err := h.blockFetcher.HeaderByNumber(ctx, nil)
+    if err != nil {
+        if ctx.Err() == nil {
+            log.Printf("Error checking balances for address watch %s: %v", sub.id, err)
+        }
+        return nil
+    }
+    number := header.Number.Uint64()
+    accounts, err := h.blockFetcher.GetAccounts(ctx, w.addresses, blockchain.BlockNumberArg(number), false)
+    if err != nil {
+        if ctx.Err() == nil {
+            log.Printf("Error checking balances for address watch %s: %v", sub.id, err)
+        }
+        return nil
+    }
+
+    latest := make(map[common.Address]*big.Int, len(accounts))
+    var deltas []BalanceDelta
+    for i, account := range accounts {
+        balance, _ := new(big.Int).SetString(account.BalanceWei, 10)
+        latest[w.addresses[i]] = balance
+        if previous == nil {
+            continue
+        }
+        before, ok := previous[w.addresses[i]]
+        if !ok || before.Cmp(balance) == 0 {
+            continue
+        }
+        delta := new(big.Int).Sub(balance, before)
+        deltas = append(deltas, BalanceDelta{
+            Address:      account.Address,
+            BalanceWei:   account.BalanceWei,
+            BalanceEther: account.BalanceEther,
+            DeltaWei:     delta.String(),
+            DeltaEther:   blockchain.FormatEther(delta),
+        })
+    }
+    if len(deltas) > 0 {
+        h.notify(sub, map[string]interface{}{
+            "type": "balanceDelta",
+            "data": map[string]interface{}{
+                "blockNumber": number,
+                "balances":    deltas,
+            },
+        })
+    }
+    return latest
+}
@@ -0,0 +1,35 @@
+package websocket
+
+import (
+    "encoding/json"
+)
+
+/**
+  *  Error codes follow JSON-RPC 2.0. Codes in the -32000 to -32099 range are
+  *  gateway-specific.
+  */
+const (
+    ErrCodeParse          = -32700
+    ErrCodeInvalidRequest = -32600
+    ErrCodeMethodNotFound = -32601
+    ErrCodeInvalidParams  = -32602
+    ErrCodeInternal       = -32603
+    ErrCodeNode           = -32000
+    ErrCodeNotSupported   = -32004
+)
+
+type WSError struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+}
+
+// sendError replies with an error message carrying the id of the request that caused it.
+func (h *WSHandler) sendError(client *Client, id json.RawMessage, code int, message string) {
+    h.reply(client, id, map[string]interface{}{
+        "type": "error",
+        "data": WSError{
+            Code:    code,
+            Message: message,
+        },
+    })
+}
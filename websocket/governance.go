@@ -0,0 +1,155 @@
+package websocket
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "log"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/sch0penheimer/eth-ws-server/blockchain"
+)
+
+// SignersRequest selects the block to list signers at; the latest block when omitted.
+type SignersRequest struct {
+    Block *blockchain.BlockNumberArg `json:"block"`
+}
+
+type ProposeRequest struct {
+    Address   string `json:"address"`
+    Authorize bool   `json:"authorize"`
+}
+
+type DiscardRequest struct {
+    Address string `json:"address"`
+}
+
+func (h *WSHandler) handleGetSigners(client *Client, msg WSMessage) {
+    var req SignersRequest
+    if len(msg.Payload) > 0 && !bytes.Equal(msg.Payload, []byte("null")) {
+        if err := json.Unmarshal(msg.Payload, &req); err != nil {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format: "+err.Error())
+            return
+        }
+    }
+    block := blockchain.LatestBlock
+    if req.Block != nil {
+        block = *req.Block
+    }
+
+    signers, number, err := h.blockFetcher.GetValidatorsAt(h.ctx, block)
+    if err != nil {
+        if errors.Is(err, blockchain.ErrNoValidatorSet) {
+            h.sendError(client, msg.ID, ErrCodeNotSupported, err.Error())
+            return
+        }
+        log.Printf("Error fetching signers: %v", err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch signers")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "signers",
+        "data": map[string]interface{}{
+            "block":   number,
+            "signers": signers,
+        },
+    })
+}
+
+func (h *WSHandler) handleGetProposals(client *Client, msg WSMessage) {
+    proposals, err := h.miningController.GetProposals(h.ctx)
+    if err != nil {
+        log.Printf("Error fetching proposals: %v", err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch proposals")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "proposals",
+        "data": proposals,
+    })
+}
+
+func (h *WSHandler) handlePropose(client *Client, msg WSMessage) {
+    var req ProposeRequest
+    if err := json.Unmarshal(msg.Payload, &req); err != nil {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format")
+        return
+    }
+    if !common.IsHexAddress(req.Address) {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid address: "+req.Address)
+        return
+    }
+    address := common.HexToAddress(req.Address)
+
+    results := h.miningController.Propose(h.ctx, address, req.Authorize)
+    log.Printf("Client %v proposed %s (authorize: %t)", client.conn.RemoteAddr(), address.Hex(), req.Authorize)
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type":   "propose",
+        "status": logNodeFailures("propose "+address.Hex(), results),
+        "data": map[string]interface{}{
+            "address":   address,
+            "authorize": req.Authorize,
+            "nodes":     results,
+        },
+    })
+}
+
+func (h *WSHandler) handleDiscard(client *Client, msg WSMessage) {
+    var req DiscardRequest
+    if err := json.Unmarshal(msg.Payload, &req); err != nil {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format")
+        return
+    }
+    if !common.IsHexAddress(req.Address) {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid address: "+req.Address)
+        return
+    }
+    address := common.HexToAddress(req.Address)
+
+    results := h.miningController.Discard(h.ctx, address)
+    log.Printf("Client %v discarded the proposal for %s", client.conn.RemoteAddr(), address.Hex())
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type":   "discard",
+        "status": logNodeFailures("discard the proposal for "+address.Hex(), results),
+        "data": map[string]interface{}{
+            "address": address,
+            "nodes":   results,
+        },
+    })
+}
+
+// logNodeFailures logs every node that failed to apply action and reports whether all of them succeeded.
+func logNodeFailures(action string, results []blockchain.NodeResult) bool {
+    ok := len(results) > 0
+    for _, result := range results {
+        if !result.Success {
+            log.Printf("Node %s failed to %s: %s", result.URL, action, result.Error)
+            ok = false
+        }
+    }
+    return ok
+}
+
+// publishSignerSetChange tells signers subscribers when a checkpoint changes the signer set.
+func (h *WSHandler) publishSignerSetChange(header *types.Header) {
+    if !h.hasSubscribers(TopicSigners) {
+        return
+    }
+    change, err := h.blockFetcher.SignerSetChange(h.ctx, header)
+    if err != nil {
+        log.Printf("Error checking signer set at block %v: %v", header.Number, err)
+        return
+    }
+    if change == nil {
+        return
+    }
+    h.publish(TopicSigners, map[string]interface{}{
+        "type": "signerSetChanged",
+        "data": change,
+    })
+}
@@ -0,0 +1,94 @@
+package websocket
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "log"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/sch0penheimer/eth-ws-server/blockchain"
+)
+
+/**
+  *  BlockRequest selects one block by number, tag or hash; the latest block
+  *  when omitted. Transactions are listed by hash unless FullTransactions is set.
+  */
+type BlockRequest struct {
+    Block            *blockchain.BlockNumberOrHash `json:"block"`
+    FullTransactions bool                          `json:"fullTransactions"`
+}
+
+// blockWithHashes is a Block whose transactions are listed by hash only.
+type blockWithHashes struct {
+    blockchain.Block
+    Transactions []string `json:"transactions"`
+}
+
+func (h *WSHandler) handleGetBlock(client *Client, msg WSMessage) {
+    var req BlockRequest
+    if len(msg.Payload) > 0 && !bytes.Equal(msg.Payload, []byte("null")) {
+        if err := json.Unmarshal(msg.Payload, &req); err != nil {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format: "+err.Error())
+            return
+        }
+    }
+    latest := blockchain.LatestBlock
+    ref := blockchain.BlockNumberOrHash{Number: &latest}
+    if req.Block != nil {
+        ref = *req.Block
+    }
+
+    block, err := h.blockFetcher.GetBlock(h.ctx, ref)
+    if err != nil {
+        if errors.Is(err, ethereum.NotFound) {
+            h.reply(client, msg.ID, map[string]interface{}{
+                "type": "block",
+                "data": nil,
+            })
+            return
+        }
+        log.Printf("Error fetching block %s: %v", ref, err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch block")
+        return
+    }
+
+    var data interface{} = block
+    if !req.FullTransactions {
+        hashes := make([]string, len(block.Transactions))
+        for i, tx := range block.Transactions {
+            hashes[i] = tx.Hash
+        }
+        data = blockWithHashes{Block: *block, Transactions: hashes}
+    }
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "block",
+        "data": data,
+    })
+}
+
+func (h *WSHandler) handleGetBlocks(client *Client, msg WSMessage) {
+    var req blockchain.BlockPageRequest
+    if len(msg.Payload) > 0 && !bytes.Equal(msg.Payload, []byte("null")) {
+        if err := json.Unmarshal(msg.Payload, &req); err != nil {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format: "+err.Error())
+            return
+        }
+    }
+
+    page, err := h.blockFetcher.GetBlockPage(h.ctx, req)
+    if err != nil {
+        if errors.Is(err, blockchain.ErrInvalidPageRequest) {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, err.Error())
+            return
+        }
+        log.Printf("Error fetching block page: %v", err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch blocks")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "blocks",
+        "data": page,
+    })
+}
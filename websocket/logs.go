@@ -0,0 +1,34 @@
+package websocket
+
+import (
+    "encoding/json"
+    "errors"
+    "log"
+
+    "github.com/sch0penheimer/eth-ws-server/blockchain"
+)
+
+// handleGetLogs serves one-shot historical log queries in the eth_getLogs filter shape.
+func (h *WSHandler) handleGetLogs(client *Client, msg WSMessage) {
+    var filter blockchain.LogFilter
+    if err := json.Unmarshal(msg.Payload, &filter); err != nil {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid logs filter: "+err.Error())
+        return
+    }
+
+    logs, err := h.blockFetcher.GetLogs(h.ctx, filter)
+    if err != nil {
+        if errors.Is(err, blockchain.ErrBlockRangeTooLarge) || errors.Is(err, blockchain.ErrInvalidBlockRange) {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, err.Error())
+            return
+        }
+        log.Printf("Error fetching logs: %v", err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch logs")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "logs",
+        "data": logs,
+    })
+}
@@ -0,0 +1,226 @@
+package websocket
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "math"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/sch0penheimer/eth-ws-server/blockchain"
+)
+
+const (
+    // pendingRateLimit caps the pending transactions delivered to one client per second.
+    pendingRateLimit = 50
+    pendingQueueSize = 256
+    pendingResolvers = 4
+)
+
+/**
+  *  PendingTransactionsParams tunes a pendingTransactions subscription. Full
+  *  resolves every hash to its transaction details. From and To keep only
+  *  transactions sent by, or addressed to, one of the listed addresses; both
+  *  must match when both are set. MaxPerSecond lowers the per-client cap for
+  *  this subscription.
+  */
+type PendingTransactionsParams struct {
+    Full         bool             `json:"full"`
+    From         []common.Address `json:"from"`
+    To           []common.Address `json:"to"`
+    MaxPerSecond int              `json:"maxPerSecond"`
+}
+
+func parsePendingParams(raw json.RawMessage) (PendingTransactionsParams, error) {
+    var p PendingTransactionsParams
+    if len(raw) == 0 {
+        return p, nil
+    }
+    if err := json.Unmarshal(raw, &p); err != nil {
+        return p, fmt.Errorf("invalid pendingTransactions params: %v", err)
+    }
+    if p.MaxPerSecond < 0 {
+        return p, errors.New("maxPerSecond must not be negative")
+    }
+    if p.MaxPerSecond > pendingRateLimit {
+        p.MaxPerSecond = pendingRateLimit
+    }
+    return p, nil
+}
+
+func (p PendingTransactionsParams) needsDetails() bool {
+    return p.Full || len(p.From) > 0 || len(p.To) > 0
+}
+
+func (p PendingTransactionsParams) matches(tx *blockchain.PendingTransaction) bool {
+    return matchAddress(p.From, tx.From) && matchAddress(p.To, tx.To)
+}
+
+func matchAddress(addresses []common.Address, hex string) bool {
+    if len(addresses) == 0 {
+        return true
+    }
+    addr := common.HexToAddress(hex)
+    for _, a := range addresses {
+        if a == addr {
+            return true
+        }
+    }
+    return false
+}
+
+/**
+  *  runPendingFeed relays the node's mempool to one subscription. Hashes are
+  *  resolved by a few workers when details or address filters are requested.
+  *  Whatever exceeds the resolver queue or the rate caps is dropped rather
+  *  than queued, and the number of dropped transactions is reported on the
+  *  next notification.
+  */
+func (h *WSHandler) runPendingFeed(ctx context.Context, sub *subscription, params PendingTransactionsParams) {
+    hashes := make(chan common.Hash, pendingQueueSize)
+    upstream := h.resubscribe(TopicPendingTransactions, func(ctx context.Context) (ethereum.Subscription, error) {
+        return h.blockFetcher.SubscribePendingTransactions(ctx, hashes)
+    })
+    defer upstream.Unsubscribe()
+
+    var limiter *rateLimiter
+    if params.MaxPerSecond > 0 {
+        limiter = newRateLimiter(params.MaxPerSecond)
+    }
+    var dropped atomic.Uint64
+    deliver := func(data interface{}) {
+        if !allowBoth(limiter, sub.client.pendingLimiter) {
+            dropped.Add(1)
+            return
+        }
+        notification := map[string]interface{}{
+            "type": "pendingTransaction",
+            "data": data,
+        }
+        if n := dropped.Swap(0); n > 0 {
+            notification["dropped"] = n
+        }
+        h.notify(sub, notification)
+    }
+
+    if !params.needsDetails() {
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case hash := <-hashes:
+                deliver(map[string]string{"hash": hash.Hex()})
+            }
+        }
+    }
+
+    queue := make(chan common.Hash, pendingQueueSize)
+    var wg sync.WaitGroup
+    defer wg.Wait()
+    for i := 0; i < pendingResolvers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for {
+                select {
+                case <-ctx.Done():
+                    return
+                case hash := <-queue:
+                    tx, err := h.blockFetcher.GetPendingTransaction(ctx, hash)
+                    if err != nil {
+                        if !errors.Is(err, ethereum.NotFound) && ctx.Err() == nil {
+                            log.Printf("Error resolving pending transaction: %v", err)
+                        }
+                        continue
+                    }
+                    if !params.matches(tx) {
+                        continue
+                    }
+                    if params.Full {
+                        deliver(tx)
+                    } else {
+                        deliver(map[string]string{"hash": tx.Hash})
+                    }
+                }
+            }
+        }()
+    }
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case hash := <-hashes:
+            select {
+            case queue <- hash:
+            default:
+                dropped.Add(1)
+            }
+        }
+    }
+}
+
+// rateLimiter is a token bucket refilled at rate tokens per second, holding at most one second's worth.
+type rateLimiter struct {
+    mu     sync.Mutex
+    rate   float64
+    tokens float64
+    last   time.Time
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+    return &rateLimiter{
+        rate:   float64(perSecond),
+        tokens: float64(perSecond),
+        last:   time.Now(),
+    }
+}
+
+func (l *rateLimiter) Allow() bool {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    l.refill(time.Now())
+    if l.tokens < 1 {
+        return false
+    }
+    l.tokens--
+    return true
+}
+
+// refill adds the tokens accrued since the last call; callers hold l.mu.
+func (l *rateLimiter) refill(now time.Time) {
+    l.tokens = math.Min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+    l.last = now
+}
+
+/**
+  *  allowBoth takes a token from sub and from client only when both have one,
+  *  so a message dropped by one limiter does not use up the other. sub may be
+  *  nil. sub is always locked before client.
+  */
+func allowBoth(sub, client *rateLimiter) bool {
+    if sub == nil {
+        return client.Allow()
+    }
+    sub.mu.Lock()
+    defer sub.mu.Unlock()
+    client.mu.Lock()
+    defer client.mu.Unlock()
+
+    now := time.Now()
+    sub.refill(now)
+    client.refill(now)
+    if sub.tokens < 1 || client.tokens < 1 {
+        return false
+    }
+    sub.tokens--
+    client.tokens--
+    return true
+}
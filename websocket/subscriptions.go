@@ -0,0 +1,434 @@
+package websocket
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "reflect"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/event"
+    "github.com/ethereum/go-ethereum/rpc"
+    "github.com/sch0penheimer/eth-ws-server/blockchain"
+)
+
+const (
+    TopicNewHeads            = "newHeads"
+    TopicNewBlocks           = "newBlocks"
+    TopicPendingTransactions = "pendingTransactions"
+    TopicLogs                = "logs"
+    TopicMiningStatus        = "miningStatus"
+    TopicMetrics             = "metrics"
+    TopicSigners             = "signers"
+    TopicWatchTransaction    = "watchTransaction"
+    TopicWatchAddress        = "watchAddress"
+)
+
+const (
+    miningStatusInterval = 5 * time.Second
+    feedBackoffMax       = 30 * time.Second
+)
+
+var topics = map[string]bool{
+    TopicNewHeads:            true,
+    TopicNewBlocks:           true,
+    TopicPendingTransactions: true,
+    TopicLogs:                true,
+    TopicMiningStatus:        true,
+    TopicMetrics:             true,
+    TopicSigners:             true,
+    TopicWatchTransaction:    true,
+    TopicWatchAddress:        true,
+}
+
+// SubscribeRequest selects a topic. An empty payload subscribes to newBlocks.
+type SubscribeRequest struct {
+    Topic  string          `json:"topic"`
+    Params json.RawMessage `json:"params,omitempty"`
+}
+
+// UnsubscribeRequest cancels a subscription by id, or every subscription to a topic.
+type UnsubscribeRequest struct {
+    Subscription string `json:"subscription"`
+    Topic        string `json:"topic"`
+}
+
+type subscription struct {
+    id     string
+    topic  string
+    params string
+    client *Client
+    cancel context.CancelFunc
+    heads  chan blockchain.HeadEvent // head events of watchTransaction and watchAddress subscriptions
+}
+
+/**
+  *  handleSubscribe is idempotent: subscribing twice to the same topic with the
+  *  same params returns the existing subscription id.
+  */
+func (h *WSHandler) handleSubscribe(client *Client, msg WSMessage) {
+    req := SubscribeRequest{Topic: TopicNewBlocks}
+    if len(msg.Payload) > 0 && !bytes.Equal(msg.Payload, []byte("null")) {
+        if err := json.Unmarshal(msg.Payload, &req); err != nil {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format")
+            return
+        }
+        if req.Topic == "" {
+            req.Topic = TopicNewBlocks
+        }
+    }
+    if !topics[req.Topic] {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "unknown topic: "+req.Topic)
+        return
+    }
+    params := canonicalParams(req.Params)
+
+    h.mu.Lock()
+    subs, ok := h.subscriptions[client]
+    if !ok {
+        h.mu.Unlock()
+        return
+    }
+    var sub *subscription
+    for _, existing := range subs {
+        if existing.topic == req.Topic && existing.params == params {
+            sub = existing
+            break
+        }
+    }
+    created := sub == nil
+    if created {
+        sub = &subscription{
+            id:     string(rpc.NewID()),
+            topic:  req.Topic,
+            params: params,
+            client: client,
+        }
+        if err := h.startSubscription(sub, req.Params); err != nil {
+            h.mu.Unlock()
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, err.Error())
+            return
+        }
+        subs[sub.id] = sub
+        log.Printf("Client %v subscribed to %s (%s)", client.conn.RemoteAddr(), sub.topic, sub.id)
+    }
+    // Feeds notify under h.mu, so queueing the reply before unlocking puts it ahead of the first notification.
+    h.replyLocked(client, msg.ID, map[string]interface{}{
+        "type":    "subscribe",
+        "status":  true,
+        "message": "Subscribed to " + sub.topic,
+        "data": map[string]string{
+            "subscription": sub.id,
+            "topic":        sub.topic,
+        },
+    })
+    h.mu.Unlock()
+
+    if created {
+        h.sendInitialSnapshot(sub)
+    }
+}
+
+// handleUnsubscribe is idempotent: unknown subscriptions report status false.
+func (h *WSHandler) handleUnsubscribe(client *Client, msg WSMessage) {
+    req := UnsubscribeRequest{Topic: TopicNewBlocks}
+    if len(msg.Payload) > 0 && !bytes.Equal(msg.Payload, []byte("null")) {
+        req = UnsubscribeRequest{}
+        if err := json.Unmarshal(msg.Payload, &req); err != nil {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format")
+            return
+        }
+        if req.Subscription == "" && req.Topic == "" {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, "subscription or topic is required")
+            return
+        }
+    }
+
+    removed := []string{}
+    h.mu.Lock()
+    for id, sub := range h.subscriptions[client] {
+        if id == req.Subscription || (req.Subscription == "" && sub.topic == req.Topic) {
+            if sub.cancel != nil {
+                sub.cancel()
+            }
+            delete(h.subscriptions[client], id)
+            removed = append(removed, id)
+        }
+    }
+    h.mu.Unlock()
+
+    if len(removed) > 0 {
+        log.Printf("Client %v unsubscribed from %v", client.conn.RemoteAddr(), removed)
+    }
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type":   "unsubscribe",
+        "status": len(removed) > 0,
+        "data": map[string]interface{}{
+            "subscriptions": removed,
+        },
+    })
+}
+
+/**
+  *  startSubscription validates the topic params and starts the upstream feed
+  *  of topics that need one of their own. Head-driven and polled topics are
+  *  served by watchNewBlocks and pollTopics. Called with h.mu held, so it must
+  *  not block.
+  */
+func (h *WSHandler) startSubscription(sub *subscription, params json.RawMessage) error {
+    switch sub.topic {
+    case TopicLogs:
+        var filter blockchain.LogFilter
+        if sub.params != "" {
+            if err := json.Unmarshal(params, &filter); err != nil {
+                return fmt.Errorf("invalid logs filter: %v", err)
+            }
+            if filter.BlockHash != nil || filter.FromBlock != nil || filter.ToBlock != nil {
+                return errors.New("logs subscriptions do not accept blockHash, fromBlock or toBlock")
+            }
+        }
+        query := filter.Query()
+        h.startFeed(sub, func(ctx context.Context) {
+            h.runLogsFeed(ctx, sub, query)
+        })
+    case TopicPendingTransactions:
+        pending, err := parsePendingParams(params)
+        if err != nil {
+            return err
+        }
+        h.startFeed(sub, func(ctx context.Context) {
+            h.runPendingFeed(ctx, sub, pending)
+        })
+    case TopicWatchTransaction:
+        watch, err := parseWatchParams(params)
+        if err != nil {
+            return err
+        }
+        watches := 0
+        for _, existing := range h.subscriptions[sub.client] {
+            if existing.topic == TopicWatchTransaction {
+                watches++
+            }
+        }
+        if watches >= maxTransactionWatches {
+            return fmt.Errorf("at most %d transactions can be watched per connection", maxTransactionWatches)
+        }
+        sub.heads = make(chan blockchain.HeadEvent, watchQueueSize)
+        h.startFeed(sub, func(ctx context.Context) {
+            h.runTransactionWatch(ctx, sub, watch)
+            h.endSubscription(sub)
+        })
+    case TopicWatchAddress:
+        watch, err := parseAddressWatchParams(params)
+        if err != nil {
+            return err
+        }
+        watches := 0
+        for _, existing := range h.subscriptions[sub.client] {
+            if existing.topic == TopicWatchAddress {
+                watches++
+            }
+        }
+        if watches >= maxAddressWatches {
+            return fmt.Errorf("at most %d address watches can be open per connection", maxAddressWatches)
+        }
+        sub.heads = make(chan blockchain.HeadEvent, watchQueueSize)
+        h.startFeed(sub, func(ctx context.Context) {
+            h.runAddressWatch(ctx, sub, watch)
+        })
+    }
+    return nil
+}
+
+func (h *WSHandler) startFeed(sub *subscription, run func(ctx context.Context)) {
+    ctx, cancel := context.WithCancel(h.ctx)
+    sub.cancel = cancel
+    h.wg.Add(1)
+    go func() {
+        defer h.wg.Done()
+        run(ctx)
+    }()
+}
+
+// resubscribe keeps an upstream subscription alive until it is unsubscribed.
+func (h *WSHandler) resubscribe(topic string, subscribe func(ctx context.Context) (ethereum.Subscription, error)) event.Subscription {
+    return event.ResubscribeErr(feedBackoffMax, func(ctx context.Context, lastErr error) (event.Subscription, error) {
+        if lastErr != nil {
+            log.Printf("Upstream %s subscription failed: %v", topic, lastErr)
+        }
+        return subscribe(ctx)
+    })
+}
+
+func (h *WSHandler) runLogsFeed(ctx context.Context, sub *subscription, query ethereum.FilterQuery) {
+    logs := make(chan types.Log, 256)
+    upstream := h.resubscribe(TopicLogs, func(ctx context.Context) (ethereum.Subscription, error) {
+        return h.blockFetcher.SubscribeFilterLogs(ctx, query, logs)
+    })
+    defer upstream.Unsubscribe()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case l := <-logs:
+            // Logs of blocks dropped by a reorg are re-sent with "removed": true.
+            h.notify(sub, map[string]interface{}{
+                "type": "log",
+                "data": l,
+            })
+        }
+    }
+}
+
+// sendInitialSnapshot gives polled topics their current value right away.
+func (h *WSHandler) sendInitialSnapshot(sub *subscription) {
+    switch sub.topic {
+    case TopicMiningStatus:
+        if statuses, err := h.miningController.GetMiningStatus(h.ctx); err == nil {
+            h.notify(sub, map[string]interface{}{"type": "miningStatus", "data": statuses})
+        }
+    case TopicMetrics:
+        if metrics, err := h.metrics.Snapshot(h.ctx); err == nil {
+            h.notify(sub, map[string]interface{}{"type": "metrics", "data": metrics})
+        }
+    }
+}
+
+/**
+  *  pollTopics refreshes the polled topics while they have subscribers.
+  *  Mining status is only pushed when it changes, metrics on every refresh
+  *  of the collector.
+  */
+func (h *WSHandler) pollTopics() {
+    defer h.wg.Done()
+
+    miningTicker := time.NewTicker(miningStatusInterval)
+    defer miningTicker.Stop()
+    metricsUpdates := h.metrics.Updates()
+
+    var lastStatuses []bool
+    for {
+        select {
+        case <-h.quit:
+            return
+        case <-miningTicker.C:
+            if !h.hasSubscribers(TopicMiningStatus) {
+                lastStatuses = nil
+                continue
+            }
+            statuses, err := h.miningController.GetMiningStatus(h.ctx)
+            if err != nil {
+                log.Printf("Error polling mining status: %v", err)
+                continue
+            }
+            if reflect.DeepEqual(statuses, lastStatuses) {
+                continue
+            }
+            lastStatuses = statuses
+            h.publish(TopicMiningStatus, map[string]interface{}{
+                "type": "miningStatus",
+                "data": statuses,
+            })
+        case metrics, ok := <-metricsUpdates:
+            if !ok {
+                metricsUpdates = nil
+                continue
+            }
+            if !h.hasSubscribers(TopicMetrics) {
+                continue
+            }
+            h.publish(TopicMetrics, map[string]interface{}{
+                "type": "metrics",
+                "data": metrics,
+            })
+        }
+    }
+}
+
+func (h *WSHandler) hasSubscribers(topic string) bool {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    for _, subs := range h.subscriptions {
+        for _, sub := range subs {
+            if sub.topic == topic {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// publish sends a notification to every subscription of topic.
+func (h *WSHandler) publish(topic string, notification map[string]interface{}) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    for _, subs := range h.subscriptions {
+        for _, sub := range subs {
+            if sub.topic == topic {
+                h.notifyLocked(sub, notification)
+            }
+        }
+    }
+}
+
+func (h *WSHandler) notify(sub *subscription, notification map[string]interface{}) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if _, ok := h.subscriptions[sub.client][sub.id]; !ok {
+        return
+    }
+    h.notifyLocked(sub, notification)
+}
+
+// notifyLocked tags a notification with the subscription id; callers hold h.mu.
+func (h *WSHandler) notifyLocked(sub *subscription, notification map[string]interface{}) {
+    msg := make(map[string]interface{}, len(notification)+1)
+    for k, v := range notification {
+        msg[k] = v
+    }
+    msg["subscription"] = sub.id
+    h.sendLocked(sub.client, msg)
+}
+
+/**
+  *  endSubscription releases a subscription whose feed finished on its own,
+  *  so it stops receiving head events, frees its slot and a new subscribe
+  *  with the same params starts afresh.
+  */
+func (h *WSHandler) endSubscription(sub *subscription) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if h.subscriptions[sub.client][sub.id] == sub {
+        delete(h.subscriptions[sub.client], sub.id)
+    }
+    sub.cancel()
+}
+
+func (h *WSHandler) cancelSubscriptionsLocked(client *Client) {
+    for _, sub := range h.subscriptions[client] {
+        if sub.cancel != nil {
+            sub.cancel()
+        }
+    }
+    delete(h.subscriptions, client)
+}
+
+func canonicalParams(params json.RawMessage) string {
+    if len(params) == 0 {
+        return ""
+    }
+    var buf bytes.Buffer
+    if err := json.Compact(&buf, params); err != nil {
+        return string(params)
+    }
+    if buf.String() == "null" {
+        return ""
+    }
+    return buf.String()
+}
@@ -0,0 +1,125 @@
+package websocket
+
+import (
+    "encoding/json"
+    "errors"
+    "log"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/sch0penheimer/eth-ws-server/blockchain"
+)
+
+type TransactionRequest struct {
+    Hash *common.Hash `json:"hash"`
+}
+
+// SendRawTransactionRequest carries a signed transaction in its 0x-prefixed binary encoding.
+type SendRawTransactionRequest struct {
+    Raw hexutil.Bytes `json:"raw"`
+}
+
+// parseTransactionRequest decodes the hash of a transaction query, replying with an error when it is missing or invalid.
+func (h *WSHandler) parseTransactionRequest(client *Client, msg WSMessage) (common.Hash, bool) {
+    var req TransactionRequest
+    if err := json.Unmarshal(msg.Payload, &req); err != nil {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format: "+err.Error())
+        return common.Hash{}, false
+    }
+    if req.Hash == nil {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "hash is required")
+        return common.Hash{}, false
+    }
+    return *req.Hash, true
+}
+
+func (h *WSHandler) handleGetTransaction(client *Client, msg WSMessage) {
+    hash, ok := h.parseTransactionRequest(client, msg)
+    if !ok {
+        return
+    }
+
+    tx, err := h.blockFetcher.GetTransaction(h.ctx, hash)
+    if err != nil && !errors.Is(err, ethereum.NotFound) {
+        log.Printf("Error fetching transaction %s: %v", hash.Hex(), err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch transaction")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "transaction",
+        "data": tx,
+    })
+}
+
+func (h *WSHandler) handleGetTransactionReceipt(client *Client, msg WSMessage) {
+    hash, ok := h.parseTransactionRequest(client, msg)
+    if !ok {
+        return
+    }
+
+    receipt, err := h.blockFetcher.GetTransactionReceipt(h.ctx, hash)
+    if err != nil && !errors.Is(err, ethereum.NotFound) {
+        log.Printf("Error fetching receipt of %s: %v", hash.Hex(), err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch transaction receipt")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "transactionReceipt",
+        "data": receipt,
+    })
+}
+
+func (h *WSHandler) handleTraceTransaction(client *Client, msg WSMessage) {
+    hash, ok := h.parseTransactionRequest(client, msg)
+    if !ok {
+        return
+    }
+
+    trace, err := h.blockFetcher.TraceTransaction(h.ctx, hash)
+    if err != nil && !errors.Is(err, ethereum.NotFound) {
+        if errors.Is(err, blockchain.ErrTracingNotSupported) {
+            h.sendError(client, msg.ID, ErrCodeNotSupported, err.Error())
+            return
+        }
+        log.Printf("Error tracing transaction %s: %v", hash.Hex(), err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to trace transaction")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type": "trace",
+        "data": trace,
+    })
+}
+
+func (h *WSHandler) handleSendRawTransaction(client *Client, msg WSMessage) {
+    var req SendRawTransactionRequest
+    if err := json.Unmarshal(msg.Payload, &req); err != nil {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format: "+err.Error())
+        return
+    }
+    if len(req.Raw) == 0 {
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "raw is required")
+        return
+    }
+
+    result, err := h.blockFetcher.SendRawTransaction(h.ctx, req.Raw)
+    if err != nil {
+        if errors.Is(err, blockchain.ErrInvalidTransaction) {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, err.Error())
+            return
+        }
+        log.Printf("Error sending transaction: %v", err)
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to send transaction")
+        return
+    }
+
+    h.reply(client, msg.ID, map[string]interface{}{
+        "type":   "sendRawTransaction",
+        "status": result.Accepted,
+        "data":   result,
+    })
+}
@@ -0,0 +1,207 @@
+package websocket
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/sch0penheimer/eth-ws-server/blockchain"
+)
+
+const (
+    defaultWatchConfirmations = 6
+    maxWatchConfirmations     = 1000
+    defaultWatchTimeout       = 5 * time.Minute
+    maxTransactionWatches     = 64 // per client
+    watchQueueSize            = 64
+)
+
+const (
+    TxStatusPending   = "pending"
+    TxStatusIncluded  = "included"
+    TxStatusConfirmed = "confirmed"
+    TxStatusReorged   = "reorged"
+    TxStatusDropped   = "dropped"
+)
+
+/**
+  *  WatchTransactionParams selects the transaction to follow. Confirmations is
+  *  the depth at which it is reported confirmed (6 by default) and Timeout the
+  *  number of seconds it may stay pending before it is reported dropped (300
+  *  by default).
+  */
+type WatchTransactionParams struct {
+    Hash          *common.Hash `json:"hash"`
+    Confirmations *uint64      `json:"confirmations"`
+    Timeout       *uint64      `json:"timeout"`
+}
+
+// TransactionStatus is the data of a transactionStatus notification.
+type TransactionStatus struct {
+    Hash          string `json:"hash"`
+    Status        string `json:"status"`
+    BlockNumber   uint64 `json:"blockNumber,omitempty"`
+    BlockHash     string `json:"blockHash,omitempty"`
+    Confirmations uint64 `json:"confirmations,omitempty"`
+    Reason        string `json:"reason,omitempty"`
+}
+
+type transactionWatch struct {
+    hash          common.Hash
+    confirmations uint64
+    timeout       time.Duration
+}
+
+func parseWatchParams(raw json.RawMessage) (transactionWatch, error) {
+    var p WatchTransactionParams
+    if len(raw) > 0 {
+        if err := json.Unmarshal(raw, &p); err != nil {
+            return transactionWatch{}, fmt.Errorf("invalid watchTransaction params: %v", err)
+        }
+    }
+    if p.Hash == nil {
+        return transactionWatch{}, errors.New("watchTransaction requires a transaction hash")
+    }
+    w := transactionWatch{
+        hash:          *p.Hash,
+        confirmations: defaultWatchConfirmations,
+        timeout:       defaultWatchTimeout,
+    }
+    if p.Confirmations != nil {
+        if *p.Confirmations < 1 || *p.Confirmations > maxWatchConfirmations {
+            return transactionWatch{}, fmt.Errorf("confirmations must be between 1 and %d", maxWatchConfirmations)
+        }
+        w.confirmations = *p.Confirmations
+    }
+    if p.Timeout != nil {
+        if *p.Timeout == 0 {
+            return transactionWatch{}, errors.New("timeout must be positive")
+        }
+        w.timeout = time.Duration(*p.Timeout) * time.Second
+    }
+    return w, nil
+}
+
+// dispatchHeadEvent hands a head event to every transaction and address watch without blocking the head stream.
+func (h *WSHandler) dispatchHeadEvent(ev blockchain.HeadEvent) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    for _, subs := range h.subscriptions {
+        for _, sub := range subs {
+            if sub.heads == nil {
+                continue
+            }
+            select {
+            case sub.heads <- ev:
+            default:
+                log.Printf("Watch %s is lagging, head event dropped", sub.id)
+            }
+        }
+    }
+}
+
+/**
+  *  runTransactionWatch follows one transaction through the head stream. It
+  *  reports pending until a receipt appears, included once mined, and
+  *  confirmed once the block is deep enough, after which the watch ends. A
+  *  reorg that removes the inclusion block reports reorged and returns to
+  *  pending; a transaction that stays pending past the timeout is reported
+  *  dropped and the watch ends.
+  */
+func (h *WSHandler) runTransactionWatch(ctx context.Context, sub *subscription, w transactionWatch) {
+    emit := func(status TransactionStatus) {
+        status.Hash = w.hash.Hex()
+        h.notify(sub, map[string]interface{}{
+            "type": "transactionStatus",
+            "data": status,
+        })
+    }
+
+    timer := time.NewTimer(w.timeout)
+    defer timer.Stop()
+
+    var included *blockchain.TransactionReceipt
+    // check looks the receipt up again and reports any change; it returns true once the watch is over.
+    check := func(head uint64) bool {
+        receipt, err := h.blockFetcher.GetTransactionReceipt(ctx, w.hash)
+        if err != nil && !errors.Is(err, ethereum.NotFound) {
+            if ctx.Err() == nil {
+                log.Printf("Error checking transaction %s: %v", w.hash.Hex(), err)
+            }
+            return false
+        }
+        if included != nil && (receipt == nil || receipt.BlockHash != included.BlockHash) {
+            emit(TransactionStatus{Status: TxStatusReorged, BlockNumber: included.BlockNumber, BlockHash: included.BlockHash})
+            included = nil
+            timer.Reset(w.timeout)
+        }
+        if receipt == nil {
+            return false
+        }
+        if included == nil {
+            included = receipt
+            timer.Stop()
+            emit(TransactionStatus{Status: TxStatusIncluded, BlockNumber: receipt.BlockNumber, BlockHash: receipt.BlockHash, Confirmations: 1})
+        }
+        if head >= included.BlockNumber && head-included.BlockNumber+1 >= w.confirmations {
+            emit(TransactionStatus{Status: TxStatusConfirmed, BlockNumber: included.BlockNumber, BlockHash: included.BlockHash, Confirmations: head - included.BlockNumber + 1})
+            return true
+        }
+        return false
+    }
+
+    header, err := h.blockFetcher.HeaderByNumber(ctx, nil)
+    if err != nil {
+        if ctx.Err() == nil {
+            log.Printf("Error starting watch of %s: %v", w.hash.Hex(), err)
+        }
+    } else if check(header.Number.Uint64()) {
+        return
+    }
+    if included == nil {
+        emit(TransactionStatus{Status: TxStatusPending})
+    }
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-timer.C:
+            if included == nil {
+                emit(TransactionStatus{Status: TxStatusDropped, Reason: "timeout"})
+                return
+            }
+        case ev := <-sub.heads:
+            if ev.Reorg != nil {
+                if included == nil {
+                    continue
+                }
+                for _, removed := range ev.Reorg.Removed {
+                    if removed.Hash == included.BlockHash {
+                        emit(TransactionStatus{Status: TxStatusReorged, BlockNumber: included.BlockNumber, BlockHash: included.BlockHash})
+                        included = nil
+                        timer.Reset(w.timeout)
+                        break
+                    }
+                }
+                continue
+            }
+            if ev.Gap != nil {
+                // The next head looks the receipt up again, whatever was skipped.
+                continue
+            }
+            head := ev.Header.Number.Uint64()
+            if included != nil && head >= included.BlockNumber && head-included.BlockNumber+1 < w.confirmations {
+                continue
+            }
+            if check(head) {
+                return
+            }
+        }
+    }
+}
@@ -3,12 +3,14 @@ package websocket
 import (
     "context"
     "encoding/json"
+    "errors"
+    "fmt"
     "log"
     "net/http"
     "strings"
     "sync"
+    "time"
 
-    "github.com/ethereum/go-ethereum/core/types"
     "github.com/gorilla/websocket"
     "github.com/sch0penheimer/eth-ws-server/blockchain"
 )
@@ -16,26 +18,49 @@ import (
 type WSHandler struct {
     blockFetcher     *blockchain.BlockFetcher
     miningController *blockchain.MiningController
+    metrics          *blockchain.MetricsCollector
     clients          map[*Client]bool
-    subscriptions    map[*Client]bool // Tracks whether a client is subscribed to new blocks
+    subscriptions    map[*Client]map[string]*subscription // Active subscriptions of each client, by id
     register         chan *Client
     unregister       chan *Client
     broadcast        chan []byte
+    ctx              context.Context
+    cancel           context.CancelFunc
+    quit             chan struct{}
+    closed           bool
+    wg               sync.WaitGroup
     mu               sync.Mutex
 }
 
 type Client struct {
-    conn *websocket.Conn
-    send chan []byte
+    conn           *websocket.Conn
+    send           chan []byte
+    inFlight       chan struct{}
+    ordered        chan WSMessage
+    pendingLimiter *rateLimiter
 }
 
+const (
+    writeWait           = 10 * time.Second
+    closeGracePeriod    = time.Second
+    maxInFlightRequests = 8
+    orderedQueueSize    = 32
+
+    defaultLatestBlocks = 6
+    maxLatestBlocks     = 20
+)
+
+// WSMessage is a client request. ID is optional and echoed on the response.
 type WSMessage struct {
+    ID      json.RawMessage `json:"id,omitempty"`
     Type    string          `json:"type"`
     Payload json.RawMessage `json:"payload"`
 }
 
+// LatestBlocksRequest pages backwards from FromBlock, the latest block when omitted. Count defaults to 6.
 type LatestBlocksRequest struct {
-    Count int `json:"count"`
+    Count     *int                       `json:"count"`
+    FromBlock *blockchain.BlockNumberArg `json:"fromBlock,omitempty"`
 }
 
 type MiningRequest struct {
@@ -43,45 +68,112 @@ type MiningRequest struct {
 }
 
 func NewWSHandler(blockFetcher *blockchain.BlockFetcher, miningController *blockchain.MiningController) *WSHandler {
+    ctx, cancel := context.WithCancel(context.Background())
     h := &WSHandler{
+        ctx:              ctx,
+        cancel:           cancel,
         blockFetcher:     blockFetcher,
         miningController: miningController,
+        metrics:          blockchain.NewMetricsCollector(blockFetcher),
         clients:          make(map[*Client]bool),
-        subscriptions:    make(map[*Client]bool),
+        subscriptions:    make(map[*Client]map[string]*subscription),
         register:         make(chan *Client),
         unregister:       make(chan *Client),
         broadcast:        make(chan []byte),
+        quit:             make(chan struct{}),
     }
+    h.wg.Add(4)
     go h.run()
     go h.watchNewBlocks()
+    go h.pollTopics()
+    go func() {
+        defer h.wg.Done()
+        h.metrics.Run(h.ctx)
+    }()
     return h
 }
 
+/**
+  *  Shutdown stops accepting connections, flushes every client's pending
+  *  messages followed by a close frame, cancels the head subscription and
+  *  waits for all handler goroutines to exit or for ctx to expire.
+  */
+func (h *WSHandler) Shutdown(ctx context.Context) error {
+    h.mu.Lock()
+    if h.closed {
+        h.mu.Unlock()
+        return nil
+    }
+    h.closed = true
+    close(h.quit)
+    h.cancel()
+    h.mu.Unlock()
+
+    done := make(chan struct{})
+    go func() {
+        h.wg.Wait()
+        close(done)
+    }()
+    select {
+    case <-done:
+        return nil
+    case <-ctx.Done():
+        h.mu.Lock()
+        for client := range h.clients {
+            client.conn.Close()
+        }
+        h.mu.Unlock()
+        return ctx.Err()
+    }
+}
+
 func (h *WSHandler) HandleConnections(w http.ResponseWriter, r *http.Request) {
     upgrader := websocket.Upgrader{
         CheckOrigin: func(r *http.Request) bool {
             return true
         },
     }
+
+    h.mu.Lock()
+    if h.closed {
+        h.mu.Unlock()
+        http.Error(w, "gateway is shutting down", http.StatusServiceUnavailable)
+        return
+    }
+    h.wg.Add(1)
+    h.mu.Unlock()
+    defer h.wg.Done()
+
     conn, err := upgrader.Upgrade(w, r, nil)
     if err != nil {
         log.Printf("Error upgrading connection: %v", err)
         return
     }
     client := &Client{
-        conn: conn,
-        send: make(chan []byte, 256),
+        conn:           conn,
+        send:           make(chan []byte, 256),
+        inFlight:       make(chan struct{}, maxInFlightRequests),
+        ordered:        make(chan WSMessage, orderedQueueSize),
+        pendingLimiter: newRateLimiter(pendingRateLimit),
+    }
+    select {
+    case h.register <- client:
+    case <-h.quit:
+        conn.Close()
+        return
     }
-    h.register <- client
 
+    h.wg.Add(3)
     go h.writePump(client)
     go h.readPump(client)
+    go h.orderedPump(client)
 }
 
 func (h *WSHandler) writePump(client *Client) {
     defer func() {
-        h.unregister <- client
+        h.unregisterClient(client)
         client.conn.Close()
+        h.wg.Done()
     }()
     for {
         select {
@@ -89,90 +181,208 @@ func (h *WSHandler) writePump(client *Client) {
             if !ok {
                 return
             }
+            client.conn.SetWriteDeadline(time.Now().Add(writeWait))
             if err := client.conn.WriteMessage(websocket.TextMessage, message); err != nil {
                 return
             }
+        case <-h.quit:
+            h.closeClient(client)
+            return
         }
     }
 }
 
+/**
+  *  closeClient flushes whatever is still queued for the client, then sends a
+  *  close frame and gives the peer a moment to acknowledge it.
+  */
+func (h *WSHandler) closeClient(client *Client) {
+drain:
+    for {
+        select {
+        case message, ok := <-client.send:
+            if !ok {
+                return
+            }
+            client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+            if err := client.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+                return
+            }
+        default:
+            break drain
+        }
+    }
+    closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "gateway shutting down")
+    if err := client.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(writeWait)); err != nil {
+        return
+    }
+    time.Sleep(closeGracePeriod)
+}
+
 func (h *WSHandler) readPump(client *Client) {
     defer func() {
-        h.unregister <- client
+        close(client.ordered)
+        h.unregisterClient(client)
         client.conn.Close()
+        h.wg.Done()
     }()
     for {
         _, message, err := client.conn.ReadMessage()
         if err != nil {
-            log.Printf("Error reading message: %v", err)
+            if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+                log.Printf("Error reading message: %v", err)
+            }
             break
         }
         var msg WSMessage
         if err := json.Unmarshal(message, &msg); err != nil {
             log.Printf("Invalid message format: %v", err)
-            sendError(client.conn, "invalid message format")
+            h.sendError(client, nil, ErrCodeParse, "invalid message format")
             continue
         }
-        switch strings.ToLower(msg.Type) {
-        case "latestblocks":
-            h.handleLatestBlocks(client.conn, msg)
-        case "miningstatus":
-            h.handleMiningStatus(client.conn)
-        case "togglemining":
-            h.handleToggleMining(client.conn, msg)
-        case "subscribe":
-            h.handleSubscription(client)
-        default:
-            sendError(client.conn, "unknown message type")
+
+        if isOrderedRequest(msg.Type) {
+            select {
+            case client.ordered <- msg:
+            case <-h.quit:
+                return
+            }
+            continue
+        }
+
+        /**
+          *  Other requests run concurrently, bounded per client; clients match
+          *  the responses to their requests through the echoed id.
+          */
+        select {
+        case client.inFlight <- struct{}{}:
+        case <-h.quit:
+            return
         }
+        h.wg.Add(1)
+        go func(msg WSMessage) {
+            defer func() {
+                <-client.inFlight
+                h.wg.Done()
+            }()
+            h.handleMessage(client, msg)
+        }(msg)
     }
 }
 
-func (h *WSHandler) handleSubscription(client *Client) {
-    h.mu.Lock()
-    defer h.mu.Unlock()
+// orderedPump handles the requests whose effects depend on their order, one at a time, in the order they were sent.
+func (h *WSHandler) orderedPump(client *Client) {
+    defer h.wg.Done()
+    for msg := range client.ordered {
+        h.handleMessage(client, msg)
+    }
+}
 
-    if h.subscriptions[client] {
-        h.subscriptions[client] = false
-        log.Printf("Client unsubscribed from new blocks: %v", client.conn.RemoteAddr())
-    } else {
-        h.subscriptions[client] = true
-        log.Printf("Client subscribed to new blocks: %v", client.conn.RemoteAddr())
+// isOrderedRequest reports whether a request changes state that a later request of the same connection may depend on.
+func isOrderedRequest(msgType string) bool {
+    switch strings.ToLower(msgType) {
+    case "subscribe", "unsubscribe", "togglemining", "propose", "discard":
+        return true
     }
+    return false
+}
 
-    
-    response := map[string]interface{}{
-        "type":    "subscribe",
-        "status":  h.subscriptions[client],
-        "message": "Subscription status updated",
+func (h *WSHandler) handleMessage(client *Client, msg WSMessage) {
+    switch strings.ToLower(msg.Type) {
+    case "latestblocks":
+        h.handleLatestBlocks(client, msg)
+    case "miningstatus":
+        h.handleMiningStatus(client, msg)
+    case "togglemining":
+        h.handleToggleMining(client, msg)
+    case "nodehealth":
+        h.handleNodeHealth(client, msg)
+    case "getblock":
+        h.handleGetBlock(client, msg)
+    case "getblocks":
+        h.handleGetBlocks(client, msg)
+    case "gettransaction":
+        h.handleGetTransaction(client, msg)
+    case "gettransactionreceipt":
+        h.handleGetTransactionReceipt(client, msg)
+    case "tracetransaction":
+        h.handleTraceTransaction(client, msg)
+    case "sendrawtransaction":
+        h.handleSendRawTransaction(client, msg)
+    case "getbalance":
+        h.handleGetBalance(client, msg)
+    case "gettransactioncount":
+        h.handleGetTransactionCount(client, msg)
+    case "getcode":
+        h.handleGetCode(client, msg)
+    case "getstorageat":
+        h.handleGetStorageAt(client, msg)
+    case "getaccounts":
+        h.handleGetAccounts(client, msg)
+    case "getlogs":
+        h.handleGetLogs(client, msg)
+    case "getsigners":
+        h.handleGetSigners(client, msg)
+    case "getproposals":
+        h.handleGetProposals(client, msg)
+    case "propose":
+        h.handlePropose(client, msg)
+    case "discard":
+        h.handleDiscard(client, msg)
+    case "subscribe":
+        h.handleSubscribe(client, msg)
+    case "unsubscribe":
+        h.handleUnsubscribe(client, msg)
+    case "":
+        h.sendError(client, msg.ID, ErrCodeInvalidRequest, "message type is required")
+    default:
+        h.sendError(client, msg.ID, ErrCodeMethodNotFound, "unknown message type")
     }
-    if err := client.conn.WriteJSON(response); err != nil {
-        log.Printf("Error sending subscription confirmation: %v", err)
+}
+
+func (h *WSHandler) unregisterClient(client *Client) {
+    select {
+    case h.unregister <- client:
+    case <-h.quit:
     }
 }
 
-func (h *WSHandler) handleLatestBlocks(conn *websocket.Conn, msg WSMessage) {
+func (h *WSHandler) handleLatestBlocks(client *Client, msg WSMessage) {
     var req LatestBlocksRequest
     if err := json.Unmarshal(msg.Payload, &req); err != nil {
-        sendError(conn, "invalid request format")
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format")
         return
     }
 
-    if req.Count <= 0 || req.Count > 20 {
-        req.Count = 6
+    count := defaultLatestBlocks
+    if req.Count != nil {
+        count = *req.Count
+        if count < 1 || count > maxLatestBlocks {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, fmt.Sprintf("count must be between 1 and %d, got %d", maxLatestBlocks, count))
+            return
+        }
+    }
+
+    from := blockchain.LatestBlock
+    if req.FromBlock != nil {
+        from = *req.FromBlock
     }
 
-    blocks, err := h.blockFetcher.GetLatestBlocks(context.Background(), req.Count)
+    blocks, err := h.blockFetcher.GetLatestBlocks(h.ctx, count, from)
     if err != nil {
+        if errors.Is(err, blockchain.ErrInvalidBlockRange) {
+            h.sendError(client, msg.ID, ErrCodeInvalidParams, err.Error())
+            return
+        }
         log.Printf("Error fetching latest blocks: %v", err)
-        sendError(conn, "failed to fetch blocks")
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch blocks")
         return
     }
 
-    metrics, err := h.blockFetcher.GetNetworkMetrics(context.Background())
+    metrics, err := h.metrics.Snapshot(h.ctx)
     if err != nil {
         log.Printf("Error fetching network metrics: %v", err)
-        sendError(conn, "failed to fetch network metrics")
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch network metrics")
         return
     }
 
@@ -181,17 +391,18 @@ func (h *WSHandler) handleLatestBlocks(conn *websocket.Conn, msg WSMessage) {
         "data":       blocks,
         "metrics":    metrics,
     }
-
-    if err := conn.WriteJSON(response); err != nil {
-        log.Printf("Error sending blocks and metrics: %v", err)
+    if len(blocks) > 0 && blocks[len(blocks)-1].Number > 0 {
+        response["nextFromBlock"] = blocks[len(blocks)-1].Number - 1
     }
+
+    h.reply(client, msg.ID, response)
 }
 
-func (h *WSHandler) handleMiningStatus(conn *websocket.Conn) {
-    statuses, err := h.miningController.GetMiningStatus(context.Background())
+func (h *WSHandler) handleMiningStatus(client *Client, msg WSMessage) {
+    statuses, err := h.miningController.GetMiningStatus(h.ctx)
     if err != nil {
         log.Printf("Error fetching mining status: %v", err)
-        sendError(conn, "failed to fetch mining status")
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to fetch mining status")
         return
     }
 
@@ -199,22 +410,29 @@ func (h *WSHandler) handleMiningStatus(conn *websocket.Conn) {
         "type": "miningStatus",
         "data": statuses,
     }
-    if err := conn.WriteJSON(response); err != nil {
-        log.Printf("Error sending mining status response: %v", err)
+    h.reply(client, msg.ID, response)
+}
+
+// handleNodeHealth reports the health and rolling probe latency of every configured node.
+func (h *WSHandler) handleNodeHealth(client *Client, msg WSMessage) {
+    response := map[string]interface{}{
+        "type": "nodeHealth",
+        "data": h.blockFetcher.Pool().Status(),
     }
+    h.reply(client, msg.ID, response)
 }
 
-func (h *WSHandler) handleToggleMining(conn *websocket.Conn, msg WSMessage) {
+func (h *WSHandler) handleToggleMining(client *Client, msg WSMessage) {
     var req MiningRequest
     if err := json.Unmarshal(msg.Payload, &req); err != nil {
-        sendError(conn, "invalid request format")
+        h.sendError(client, msg.ID, ErrCodeInvalidParams, "invalid request format")
         return
     }
 
-    results, err := h.miningController.ToggleMining(context.Background(), req.Start)
+    results, err := h.miningController.ToggleMining(h.ctx, req.Start)
     if err != nil {
         log.Printf("Error toggling mining: %v", err)
-        sendError(conn, "failed to toggle mining")
+        h.sendError(client, msg.ID, ErrCodeNode, "failed to toggle mining")
         return
     }
 
@@ -222,86 +440,99 @@ func (h *WSHandler) handleToggleMining(conn *websocket.Conn, msg WSMessage) {
         "type": "toggleMining",
         "data": results,
     }
-    if err := conn.WriteJSON(response); err != nil {
-        log.Printf("Error sending toggle mining response: %v", err)
-    }
+    h.reply(client, msg.ID, response)
 }
 
 func (h *WSHandler) watchNewBlocks() {
-    for {
-        headers := make(chan *types.Header)
-        sub, err := h.blockFetcher.Client.SubscribeNewHead(context.Background(), headers)
-        if err != nil {
-            log.Printf("Failed to subscribe to new blocks: %v", err)
-            continue
-        }
-        defer sub.Unsubscribe()
-
-        log.Println("Subscribed to new block headers")
+    defer h.wg.Done()
 
-        for {
-            select {
-            case header := <-headers:
-                log.Printf("New block header received: %v", header.Number)
-
-                block, err := h.blockFetcher.GetBlockByNumber(context.Background(), header.Number)
-                if err != nil {
-                    log.Printf("Error fetching block details: %v", err)
-                    continue
-                }
+    tracker := blockchain.NewHeadTracker(h.blockFetcher)
+    h.wg.Add(1)
+    go func() {
+        defer h.wg.Done()
+        tracker.Run(h.ctx)
+    }()
 
-                metrics, err := h.blockFetcher.GetNetworkMetrics(context.Background())
-                if err != nil {
-                    log.Printf("Error fetching network metrics: %v", err)
-                    continue
+    for {
+        select {
+        case <-h.quit:
+            return
+        case ev, ok := <-tracker.Events():
+            if !ok {
+                return
+            }
+            if ev.Reorg != nil {
+                h.blockFetcher.ObserveReorg(ev.Reorg)
+                h.dispatchHeadEvent(ev)
+                reorg := map[string]interface{}{
+                    "type": "reorg",
+                    "data": ev.Reorg,
                 }
-
-                response := map[string]interface{}{
-                    "type":    "newBlock",
-                    "data":    block,
-                    "metrics": metrics,
+                h.publish(TopicNewHeads, reorg)
+                h.publish(TopicNewBlocks, reorg)
+                continue
+            }
+            if ev.Gap != nil {
+                h.dispatchHeadEvent(ev)
+                gap := map[string]interface{}{
+                    "type": "gap",
+                    "data": ev.Gap,
                 }
+                h.publish(TopicNewHeads, gap)
+                h.publish(TopicNewBlocks, gap)
+                continue
+            }
+            header := ev.Header
+            log.Printf("New block header received: %v", header.Number)
+            h.blockFetcher.ObserveHead(header)
+            h.dispatchHeadEvent(ev)
+
+            h.publish(TopicNewHeads, map[string]interface{}{
+                "type": "newHead",
+                "data": header,
+            })
+            h.publishSignerSetChange(header)
+            if !h.hasSubscribers(TopicNewBlocks) {
+                continue
+            }
 
-                msg, err := json.Marshal(response)
-                if err != nil {
-                    log.Printf("Error marshaling new block: %v", err)
-                    continue
-                }
+            block, err := h.blockFetcher.GetBlockByNumber(h.ctx, header.Number)
+            if err != nil {
+                log.Printf("Error fetching block details: %v", err)
+                continue
+            }
 
-                h.mu.Lock()
-                for client, subscribed := range h.subscriptions {
-                    if subscribed {
-                        select {
-                        case client.send <- msg:
-                            log.Printf("New block sent to client: %v", client.conn.RemoteAddr())
-                        default:
-                            log.Printf("Client not ready to receive new blocks: %v", client.conn.RemoteAddr())
-                        }
-                    }
-                }
-                h.mu.Unlock()
-            case err := <-sub.Err():
-                log.Printf("Block subscription error: %v", err)
-                break
+            metrics, err := h.metrics.Snapshot(h.ctx)
+            if err != nil {
+                continue
             }
+
+            h.publish(TopicNewBlocks, map[string]interface{}{
+                "type":    "newBlock",
+                "data":    block,
+                "metrics": metrics,
+            })
         }
     }
 }
 
 func (h *WSHandler) run() {
+    defer h.wg.Done()
     for {
         select {
+        case <-h.quit:
+            return
         case client := <-h.register:
             h.mu.Lock()
             h.clients[client] = true
-            h.subscriptions[client] = false
+            h.subscriptions[client] = make(map[string]*subscription)
             h.mu.Unlock()
             log.Printf("Client registered: %v", client.conn.RemoteAddr())
         case client := <-h.unregister:
             h.mu.Lock()
             if _, ok := h.clients[client]; ok {
                 delete(h.clients, client)
-                delete(h.subscriptions, client)
+                h.cancelSubscriptionsLocked(client)
                 close(client.send)
                 log.Printf("Client unregistered: %v", client.conn.RemoteAddr())
             }
@@ -314,6 +545,7 @@ func (h *WSHandler) run() {
                 default:
                     close(client.send)
                     delete(h.clients, client)
+                    h.cancelSubscriptionsLocked(client)
                     log.Printf("Client removed due to unresponsiveness: %v", client.conn.RemoteAddr())
                 }
             }
@@ -322,14 +554,44 @@ func (h *WSHandler) run() {
     }
 }
 
-func sendError(conn *websocket.Conn, message string) {
-    errMsg := map[string]interface{}{
-        "type": "error",
-        "data": map[string]string{
-            "message": message,
-        },
+/**
+  *  send queues a message on the client's send channel. All writes go through
+  *  writePump, which is the only goroutine allowed to write to the connection.
+  */
+func (h *WSHandler) send(client *Client, v interface{}) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.sendLocked(client, v)
+}
+
+// sendLocked is send for callers that already hold h.mu.
+func (h *WSHandler) sendLocked(client *Client, v interface{}) {
+    if !h.clients[client] {
+        return
+    }
+    msg, err := json.Marshal(v)
+    if err != nil {
+        log.Printf("Error marshaling message: %v", err)
+        return
+    }
+    select {
+    case client.send <- msg:
+    default:
+        log.Printf("Client not ready to receive messages: %v", client.conn.RemoteAddr())
     }
-    if err := conn.WriteJSON(errMsg); err != nil {
-        log.Printf("Error sending error message: %v", err)
+}
+
+// reply sends a response, echoing the request id when the client supplied one.
+func (h *WSHandler) reply(client *Client, id json.RawMessage, response map[string]interface{}) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.replyLocked(client, id, response)
+}
+
+// replyLocked is reply for callers that already hold h.mu.
+func (h *WSHandler) replyLocked(client *Client, id json.RawMessage, response map[string]interface{}) {
+    if len(id) > 0 {
+        response["id"] = id
     }
-}
\ No newline at end of file
+    h.sendLocked(client, response)
+}